@@ -0,0 +1,44 @@
+package backoff
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Exponential is the exponential backoff policy with the jitter.
+type Exponential struct {
+	// Initial is the delay before the 1st retry.
+	Initial time.Duration
+	// Max limits the delay.
+	Max time.Duration
+}
+
+// Delay returns the randomized delay before the retry with the specified number (starting from 0).
+// The delay is doubled for every next retry and the result is randomized within the [d/2, d) range, where d is the
+// exponential delay limited by the max.
+func (e Exponential) Delay(retry uint32) (d time.Duration) {
+	d = e.Initial
+	for i := uint32(0); i < retry && d < e.Max; i++ {
+		d *= 2
+	}
+	if e.Max > 0 && d > e.Max {
+		d = e.Max
+	}
+	if d > 1 {
+		d = d/2 + time.Duration(rand.Int63n(int64(d/2)))
+	}
+	return
+}
+
+// Sleep waits for the specified duration unless the context is done earlier.
+func Sleep(ctx context.Context, d time.Duration) (err error) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-t.C:
+	}
+	return
+}
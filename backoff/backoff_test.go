@@ -0,0 +1,37 @@
+package backoff
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestExponential_Delay(t *testing.T) {
+	e := Exponential{
+		Initial: time.Second,
+		Max:     10 * time.Second,
+	}
+	cases := map[uint32]time.Duration{
+		0:   time.Second,
+		1:   2 * time.Second,
+		2:   4 * time.Second,
+		3:   8 * time.Second,
+		4:   10 * time.Second,
+		100: 10 * time.Second,
+	}
+	for retry, d := range cases {
+		for i := 0; i < 100; i++ {
+			delay := e.Delay(retry)
+			assert.GreaterOrEqual(t, delay, d/2)
+			assert.Less(t, delay, d)
+		}
+	}
+}
+
+func TestSleep(t *testing.T) {
+	assert.Nil(t, Sleep(context.TODO(), time.Millisecond))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, Sleep(ctx, time.Minute), context.DeadlineExceeded)
+}
@@ -1,33 +1,47 @@
 package config
 
 import (
+	"fmt"
 	"github.com/kelseyhightower/envconfig"
 	"time"
 )
 
 type Config struct {
 	Api struct {
-		Writer struct {
-			Backoff   time.Duration `envconfig:"API_WRITER_BACKOFF" default:"10s" required:"true"`
-			BatchSize uint32        `envconfig:"API_WRITER_BATCH_SIZE" default:"64" required:"true"`
-			Uri       string        `envconfig:"API_WRITER_URI" default:"resolver:50051" required:"true"`
-		}
+		Writer WriterConfig
 	}
-	Db   DbConfig
-	Feed FeedConfig
-	Log  struct {
+	// Daemon defines whether to run continuously and schedule the feed updates internally instead of a single run.
+	Daemon bool `envconfig:"DAEMON" default:"false" required:"true"`
+	Db     DbConfig
+	Feed   FeedConfig
+	Log    struct {
 		Level int `envconfig:"LOG_LEVEL" default:"-4" required:"true"`
 	}
 	Message MessageConfig
 }
 
+type WriterConfig struct {
+	AttemptsMax uint32        `envconfig:"API_WRITER_ATTEMPTS_MAX" default:"10" required:"true"`
+	Backoff     time.Duration `envconfig:"API_WRITER_BACKOFF" default:"10s" required:"true"`
+	BackoffMax  time.Duration `envconfig:"API_WRITER_BACKOFF_MAX" default:"2m" required:"true"`
+	BatchSize   uint32        `envconfig:"API_WRITER_BATCH_SIZE" default:"64" required:"true"`
+	Timeout     time.Duration `envconfig:"API_WRITER_TIMEOUT" default:"10m" required:"true"`
+	Uri         string        `envconfig:"API_WRITER_URI" default:"resolver:50051" required:"true"`
+}
+
 type DbConfig struct {
 	Uri      string `envconfig:"DB_URI" default:"mongodb://localhost:27017/?retryWrites=true&w=majority" required:"true"`
 	Name     string `envconfig:"DB_NAME" default:"producer-rss" required:"true"`
 	UserName string `envconfig:"DB_USERNAME" default:""`
 	Password string `envconfig:"DB_PASSWORD" default:""`
 	Table    struct {
-		Name string `envconfig:"DB_TABLE_NAME" default:"feeds" required:"true"`
+		Name              string        `envconfig:"DB_TABLE_NAME" default:"feeds" required:"true"`
+		Outbox            string        `envconfig:"DB_TABLE_OUTBOX" default:"outbox" required:"true"`
+		OutboxTtl         time.Duration `envconfig:"DB_TABLE_OUTBOX_TTL" default:"24h" required:"true"`
+		OutboxAttemptsMax uint32        `envconfig:"DB_TABLE_OUTBOX_ATTEMPTS_MAX" default:"10" required:"true"`
+		OutboxUnsentTtl   time.Duration `envconfig:"DB_TABLE_OUTBOX_UNSENT_TTL" default:"168h" required:"true"`
+		Seen              string        `envconfig:"DB_TABLE_SEEN" default:"seen" required:"true"`
+		SeenTtl           time.Duration `envconfig:"DB_TABLE_SEEN_TTL" default:"720h" required:"true"`
 	}
 	Tls struct {
 		Enabled  bool `envconfig:"DB_TLS_ENABLED" default:"false" required:"true"`
@@ -36,21 +50,52 @@ type DbConfig struct {
 }
 
 type FeedConfig struct {
-	Url               string        `envconfig:"FEED_URL" required:"true"`
+	Url               string        `envconfig:"FEED_URL" default:""`
+	FailuresMax       uint32        `envconfig:"FEED_FAILURES_MAX" default:"5" required:"true"`
+	RedirectCountMin  uint32        `envconfig:"FEED_REDIRECT_COUNT_MIN" default:"3" required:"true"`
+	RetiredSkip       bool          `envconfig:"FEED_RETIRED_SKIP" default:"true" required:"true"`
 	TlsSkipVerify     bool          `envconfig:"FEED_TLS_SKIP_VERIFY" default:"true" required:"true"`
 	UpdateIntervalMin time.Duration `envconfig:"FEED_UPDATE_INTERVAL_MIN" default:"10s" required:"true"`
 	UpdateIntervalMax time.Duration `envconfig:"FEED_UPDATE_INTERVAL_MAX" default:"10m" required:"true"`
 	UpdateTimeout     time.Duration `envconfig:"FEED_UPDATE_TIMEOUT" default:"1m" required:"true"`
+	UpdateConcurrency uint32        `envconfig:"FEED_UPDATE_CONCURRENCY" default:"8" required:"true"`
 	UserAgent         string        `envconfig:"FEED_USER_AGENT" default:"awakari-producer-rss/0.0.1" required:"true"`
+	HostLimit         FeedHostLimitConfig
+	Retry             FeedRetryConfig
+}
+
+type FeedHostLimitConfig struct {
+	ConcurrencyMax       uint32                   `envconfig:"FEED_HOST_CONCURRENCY_MAX" default:"1" required:"true"`
+	ConcurrencyMaxByHost map[string]uint32        `envconfig:"FEED_HOST_CONCURRENCY_MAX_BY_HOST" default:""`
+	DelayMin             time.Duration            `envconfig:"FEED_HOST_DELAY_MIN" default:"1s" required:"true"`
+	DelayMinByHost       map[string]time.Duration `envconfig:"FEED_HOST_DELAY_MIN_BY_HOST" default:""`
+}
+
+type FeedRetryConfig struct {
+	AttemptsMax    uint32        `envconfig:"FEED_RETRY_ATTEMPTS_MAX" default:"3" required:"true"`
+	AttemptTimeout time.Duration `envconfig:"FEED_RETRY_ATTEMPT_TIMEOUT" default:"1m" required:"true"`
+	Backoff        time.Duration `envconfig:"FEED_RETRY_BACKOFF" default:"1s" required:"true"`
+	BackoffMax     time.Duration `envconfig:"FEED_RETRY_BACKOFF_MAX" default:"1m" required:"true"`
+	Timeout        time.Duration `envconfig:"FEED_RETRY_TIMEOUT" default:"3m" required:"true"`
 }
 
 type MessageConfig struct {
-	Metadata MetadataConfig
-	Content  ContentConfig
+	// IdScheme is the message id scheme: "uuid5" (default), "sha256" or "random", see the converter.IdScheme* constants.
+	IdScheme string `envconfig:"MSG_ID_SCHEME" default:"uuid5" required:"true"`
+	// TrackingParams are the query parameters stripped from the message source URL, "*" suffix matches any parameter
+	// name starting with the prefix.
+	TrackingParams []string `envconfig:"MSG_TRACKING_PARAMS" default:"utm_*,fbclid,gclid,dclid,msclkid,yclid,igshid,mc_cid,mc_eid,_hsenc,_hsmi,rss"`
+	// KeptParamsByFeed are the query parameters kept in the message source URL by the feed URL even when these match
+	// the tracking ones, set by the feed URLs list file.
+	KeptParamsByFeed map[string][]string `ignored:"true"`
+	Metadata         MetadataConfig
+	Content          ContentConfig
+	Categories       CategoriesConfig
 }
 
 type MetadataConfig struct {
 	//
+	KeyFeedAuthor      string `envconfig:"MSG_MD_KEY_FEED_AUTHOR" default:"feedauthor" required:"true"`
 	KeyFeedCategories  string `envconfig:"MSG_MD_KEY_FEED_CATEGORIES" default:"feedcategories" required:"true"`
 	KeyFeedDescription string `envconfig:"MSG_MD_KEY_FEED_DESCRIPTION" default:"feeddescription" required:"true"`
 	KeyFeedImageTitle  string `envconfig:"MSG_MD_KEY_FEED_IMAGE_TITLE" default:"feedimagetitle" required:"true"`
@@ -58,22 +103,70 @@ type MetadataConfig struct {
 	KeyFeedTitle       string `envconfig:"MSG_MD_KEY_FEED_TITLE" default:"feedtitle" required:"true"`
 	KeyFeedUrl         string `envconfig:"MSG_MD_KEY_FEED_URL" default:"feedurl"`
 	//
+	// KeyAuthor, KeyCategories and KeyFeedCategories are the lists encoded into the single value: comma-separated, the
+	// value containing the comma, the quote or the line break is quoted as in CSV, e.g. `"Doe, John",Jane Roe`. The
+	// blank values are dropped.
 	KeyAuthor     string `envconfig:"MSG_MD_KEY_AUTHOR" default:"author" required:"true"`
 	KeyCategories string `envconfig:"MSG_MD_KEY_CATEGORIES" default:"categories" required:"true"`
 	KeyImageTitle string `envconfig:"MSG_MD_KEY_IMAGE_TITLE" default:"imagetitle" required:"true"`
 	KeyImageUrl   string `envconfig:"MSG_MD_KEY_IMAGE_URL" default:"imageurl" required:"true"`
 	KeyLanguage   string `envconfig:"MSG_MD_KEY_LANGUAGE" default:"language" required:"true"`
+	KeyOrigUrl    string `envconfig:"MSG_MD_KEY_ORIG_URL" default:"origurl" required:"true"`
 	KeySummary    string `envconfig:"MSG_MD_KEY_SUMMARY" default:"summary" required:"true"`
 	KeyTitle      string `envconfig:"MSG_MD_KEY_TITLE" default:"title" required:"true"`
 	//
+	KeyMediaDescription  string `envconfig:"MSG_MD_KEY_MEDIA_DESCRIPTION" default:"mediadescription" required:"true"`
+	KeyMediaDuration     string `envconfig:"MSG_MD_KEY_MEDIA_DURATION" default:"mediaduration" required:"true"`
+	KeyMediaRating       string `envconfig:"MSG_MD_KEY_MEDIA_RATING" default:"mediarating" required:"true"`
+	KeyMediaThumbnailUrl string `envconfig:"MSG_MD_KEY_MEDIA_THUMBNAIL_URL" default:"mediathumbnailurl" required:"true"`
+	KeyMediaVideoId      string `envconfig:"MSG_MD_KEY_MEDIA_VIDEO_ID" default:"mediavideoid" required:"true"`
+	KeyMediaViews        string `envconfig:"MSG_MD_KEY_MEDIA_VIEWS" default:"mediaviews" required:"true"`
+	//
+	KeyEnclosureLength string `envconfig:"MSG_MD_KEY_ENCLOSURE_LENGTH" default:"enclosurelength" required:"true"`
+	KeyEnclosureType   string `envconfig:"MSG_MD_KEY_ENCLOSURE_TYPE" default:"enclosuretype" required:"true"`
+	KeyEnclosureUrl    string `envconfig:"MSG_MD_KEY_ENCLOSURE_URL" default:"enclosureurl" required:"true"`
+	//
+	KeyPodcastAuthor        string `envconfig:"MSG_MD_KEY_PODCAST_AUTHOR" default:"podcastauthor" required:"true"`
+	KeyPodcastChaptersUrl   string `envconfig:"MSG_MD_KEY_PODCAST_CHAPTERS_URL" default:"podcastchaptersurl" required:"true"`
+	KeyPodcastDuration      string `envconfig:"MSG_MD_KEY_PODCAST_DURATION" default:"podcastduration" required:"true"`
+	KeyPodcastEpisode       string `envconfig:"MSG_MD_KEY_PODCAST_EPISODE" default:"podcastepisode" required:"true"`
+	KeyPodcastExplicit      string `envconfig:"MSG_MD_KEY_PODCAST_EXPLICIT" default:"podcastexplicit" required:"true"`
+	KeyPodcastImageUrl      string `envconfig:"MSG_MD_KEY_PODCAST_IMAGE_URL" default:"podcastimageurl" required:"true"`
+	KeyPodcastSeason        string `envconfig:"MSG_MD_KEY_PODCAST_SEASON" default:"podcastseason" required:"true"`
+	KeyPodcastTranscriptUrl string `envconfig:"MSG_MD_KEY_PODCAST_TRANSCRIPT_URL" default:"podcasttranscripturl" required:"true"`
+	//
 	SpecVersion string `envconfig:"MSG_MD_SPEC_VERSION" default:"1.0" required:"true"`
 }
 
 type ContentConfig struct {
-	Type string `envconfig:"MSG_CONTENT_TYPE" default:"text/plain" required:"true"`
+	// Mode is the default content mode: "raw", "sanitized", "text" or "markdown", see the converter.ContentMode*.
+	Mode string `envconfig:"MSG_CONTENT_MODE" default:"sanitized" required:"true"`
+	// ModeByFeed is the content mode by the feed URL, set by the feed URLs list file.
+	ModeByFeed map[string]string `ignored:"true"`
+	Type       string            `envconfig:"MSG_CONTENT_TYPE" default:"text/plain" required:"true"`
+	// Trackers are the domains of the tracking pixels and links removed from the HTML content, subdomains included.
+	Trackers []string `envconfig:"MSG_CONTENT_TRACKERS" default:"doubleclick.net,feedsportal.com,google-analytics.com,mathtag.com,pixel.wp.com,quantserve.com,scorecardresearch.com,stats.wordpress.com"`
+}
+
+type CategoriesConfig struct {
+	// Normalize enables trimming, lowercasing and deduplicating the categories.
+	Normalize bool `envconfig:"MSG_CATEGORIES_NORMALIZE" default:"false"`
+	// Synonyms maps the categories to the preferred ones, e.g. "golang:go,k8s:kubernetes".
+	Synonyms map[string]string `envconfig:"MSG_CATEGORIES_SYNONYMS"`
+}
+
+// contentModes are the known content modes, see the converter.ContentMode*.
+var contentModes = map[string]bool{
+	"raw":       true,
+	"sanitized": true,
+	"text":      true,
+	"markdown":  true,
 }
 
 func NewConfigFromEnv() (cfg Config, err error) {
 	err = envconfig.Process("", &cfg)
+	if err == nil && !contentModes[cfg.Message.Content.Mode] {
+		err = fmt.Errorf("unknown content mode: %s", cfg.Message.Content.Mode)
+	}
 	return
 }
@@ -10,9 +10,12 @@ import (
 
 func TestConfig(t *testing.T) {
 	os.Setenv("API_WRITER_BACKOFF", "23h")
+	os.Setenv("API_WRITER_ATTEMPTS_MAX", "7")
 	os.Setenv("API_WRITER_URI", "writer:56789")
+	os.Setenv("DAEMON", "true")
 	os.Setenv("LOG_LEVEL", "4")
 	os.Setenv("FEED_UPDATE_TIMEOUT", "34ms")
+	os.Setenv("FEED_HOST_DELAY_MIN_BY_HOST", "www.youtube.com:5s,feeds.a.dj.com:1m")
 	os.Setenv("FEED_URL", "https://feed.rss.com")
 	os.Setenv("MSG_MD_KEY_FEED_TITLE", "feed title")
 	os.Setenv("MSG_MD_KEY_LANGUAGE", "lang")
@@ -20,10 +23,28 @@ func TestConfig(t *testing.T) {
 	cfg, err := NewConfigFromEnv()
 	assert.Nil(t, err)
 	assert.Equal(t, 23*time.Hour, cfg.Api.Writer.Backoff)
+	assert.Equal(t, uint32(7), cfg.Api.Writer.AttemptsMax)
+	assert.Equal(t, 2*time.Minute, cfg.Api.Writer.BackoffMax)
+	assert.Equal(t, 10*time.Minute, cfg.Api.Writer.Timeout)
 	assert.Equal(t, "writer:56789", cfg.Api.Writer.Uri)
+	assert.True(t, cfg.Daemon)
 	assert.Equal(t, slog.LevelWarn, slog.Level(cfg.Log.Level))
 	assert.Equal(t, 34*time.Millisecond, cfg.Feed.UpdateTimeout)
+	assert.Equal(t, uint32(8), cfg.Feed.UpdateConcurrency)
+	assert.Equal(t, time.Second, cfg.Feed.HostLimit.DelayMin)
+	assert.Equal(t, map[string]time.Duration{"www.youtube.com": 5 * time.Second, "feeds.a.dj.com": time.Minute}, cfg.Feed.HostLimit.DelayMinByHost)
+	assert.Equal(t, uint32(1), cfg.Feed.HostLimit.ConcurrencyMax)
+	assert.Empty(t, cfg.Feed.HostLimit.ConcurrencyMaxByHost)
 	assert.Equal(t, "feed title", cfg.Message.Metadata.KeyFeedTitle)
 	assert.Equal(t, "lang", cfg.Message.Metadata.KeyLanguage)
 	assert.Equal(t, "text/xml", cfg.Message.Content.Type)
+	assert.Contains(t, cfg.Message.TrackingParams, "utm_*")
+	assert.Contains(t, cfg.Message.TrackingParams, "rss")
+	assert.Equal(t, "sanitized", cfg.Message.Content.Mode)
+}
+
+func TestConfig_UnknownContentMode(t *testing.T) {
+	t.Setenv("MSG_CONTENT_MODE", "markdwn")
+	_, err := NewConfigFromEnv()
+	assert.ErrorContains(t, err, "unknown content mode")
 }
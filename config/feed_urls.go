@@ -0,0 +1,93 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const feedUrlsCommentPrefix = "#"
+const feedOptionSep = "="
+const feedOptionContent = "content"
+const feedOptionKeep = "keep"
+const feedOptionValueSep = ","
+
+// FeedList is the list of the feed URLs with the optional per feed options.
+type FeedList struct {
+	Urls []string
+	// ContentModes contains the content mode by the feed URL for the feeds having the "content" option only.
+	ContentModes map[string]string
+	// KeptParams contains the query parameters kept in the message source by the feed URL for the feeds having the
+	// "keep" option only.
+	KeptParams map[string][]string
+}
+
+// NewFeedUrlsFromFile loads the feed URLs list from the file by the specified path.
+func NewFeedUrlsFromFile(path string) (fl FeedList, err error) {
+	var f *os.File
+	f, err = os.Open(path)
+	if err == nil {
+		defer f.Close()
+		fl, err = NewFeedUrls(f)
+	}
+	return
+}
+
+// NewFeedUrls reads the feed URLs list, one URL per line, optionally followed by the options in the "key=value" format,
+// e.g. "https://feed.com/rss content=markdown". The supported options are "content" to set the feed content mode and
+// "keep" to keep the comma-separated query parameters in the message source, e.g. "keep=rss" when the feed uses the
+// parameter otherwise stripped as the tracking one to identify the article. Blank lines and comments (starting with "#" either at the line beginning or after a whitespace) are skipped.
+// Duplicate URLs are skipped too.
+func NewFeedUrls(r io.Reader) (fl FeedList, err error) {
+	fl.ContentModes = map[string]string{}
+	fl.KeptParams = map[string][]string{}
+	known := map[string]bool{}
+	s := bufio.NewScanner(r)
+	for lineNum := 1; s.Scan(); lineNum++ {
+		line := s.Text()
+		if strings.HasPrefix(line, feedUrlsCommentPrefix) {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], feedUrlsCommentPrefix) {
+			continue
+		}
+		url := fields[0]
+		if known[url] {
+			continue
+		}
+		known[url] = true
+		fl.Urls = append(fl.Urls, url)
+		for _, opt := range fields[1:] {
+			if strings.HasPrefix(opt, feedUrlsCommentPrefix) {
+				break
+			}
+			k, v, _ := strings.Cut(opt, feedOptionSep)
+			switch k {
+			case feedOptionContent:
+				if !contentModes[v] {
+					err = fmt.Errorf("line %d: unknown content mode: %s", lineNum, opt)
+					return
+				}
+				fl.ContentModes[url] = v
+			case feedOptionKeep:
+				for _, param := range strings.Split(v, feedOptionValueSep) {
+					if param != "" {
+						fl.KeptParams[url] = append(fl.KeptParams[url], strings.ToLower(param))
+					}
+				}
+				if len(fl.KeptParams[url]) == 0 {
+					err = fmt.Errorf("line %d: no query parameters to keep: %s", lineNum, opt)
+					return
+				}
+			default:
+				err = fmt.Errorf("line %d: unknown feed option: %s", lineNum, opt)
+				return
+			}
+		}
+	}
+	err = s.Err()
+	return
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestNewFeedUrls(t *testing.T) {
+	cases := map[string]struct {
+		in    string
+		urls  []string
+		modes map[string]string
+		kept  map[string][]string
+		err   bool
+	}{
+		"empty": {
+			modes: map[string]string{},
+			kept:  map[string][]string{},
+		},
+		"comments and blank lines": {
+			in: `# news
+https://hnrss.org/newest
+
+   # science
+http://export.arxiv.org/rss/cs # daily
+`,
+			urls: []string{
+				"https://hnrss.org/newest",
+				"http://export.arxiv.org/rss/cs",
+			},
+			modes: map[string]string{},
+			kept:  map[string][]string{},
+		},
+		"fragment is not a comment": {
+			in: "https://feed.com/rss#latest",
+			urls: []string{
+				"https://feed.com/rss#latest",
+			},
+			modes: map[string]string{},
+			kept:  map[string][]string{},
+		},
+		"duplicates": {
+			in: `https://feed.com/rss
+  https://feed.com/rss
+`,
+			urls: []string{
+				"https://feed.com/rss",
+			},
+			modes: map[string]string{},
+			kept:  map[string][]string{},
+		},
+		"options": {
+			in: `https://feed.com/rss content=markdown # comment content=text
+https://feed.com/atom keep=RSS,,id
+`,
+			urls: []string{
+				"https://feed.com/rss",
+				"https://feed.com/atom",
+			},
+			modes: map[string]string{
+				"https://feed.com/rss": "markdown",
+			},
+			kept: map[string][]string{
+				"https://feed.com/atom": {
+					"rss",
+					"id",
+				},
+			},
+		},
+		"unknown option": {
+			in:  "https://feed.com/rss foo=bar",
+			err: true,
+		},
+		"unknown content mode": {
+			in:  "https://feed.com/rss content=markdwn",
+			err: true,
+		},
+		"nothing to keep": {
+			in:  "https://feed.com/rss keep=",
+			err: true,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			fl, err := NewFeedUrls(strings.NewReader(c.in))
+			if c.err {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, c.urls, fl.Urls)
+				assert.Equal(t, c.modes, fl.ContentModes)
+				assert.Equal(t, c.kept, fl.KeptParams)
+			}
+		})
+	}
+}
+
+func TestNewFeedUrlsFromFile(t *testing.T) {
+	fl, err := NewFeedUrlsFromFile("feed-urls.txt")
+	assert.Nil(t, err)
+	assert.Contains(t, fl.Urls, "https://hnrss.org/newest")
+	_, err = NewFeedUrlsFromFile("missing.txt")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,35 @@
+package converter
+
+import (
+	"golang.org/x/exp/slices"
+	"strings"
+)
+
+// categories returns the categories mapped through the synonyms table. When the normalization is enabled, the
+// categories are trimmed, lowercased and deduplicated, the empty ones are dropped.
+func (c converter) categories(values []string) (categories []string) {
+	normalize := c.cfgMsg.Categories.Normalize
+	for _, v := range values {
+		if normalize {
+			v = normalizeCategory(v)
+			if v == "" {
+				continue
+			}
+		}
+		if synonym, found := c.synonyms[v]; found {
+			v = synonym
+			if normalize {
+				v = normalizeCategory(v)
+			}
+		}
+		if normalize && slices.Contains(categories, v) {
+			continue
+		}
+		categories = append(categories, v)
+	}
+	return
+}
+
+func normalizeCategory(v string) string {
+	return strings.ToLower(strings.TrimSpace(v))
+}
@@ -0,0 +1,87 @@
+package converter
+
+import (
+	"github.com/SlyMarbo/rss"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/slog"
+	"producer-rss/config"
+	"producer-rss/feeds"
+	"testing"
+)
+
+func TestConverter_Convert_Categories(t *testing.T) {
+	cases := map[string]struct {
+		cfg            config.CategoriesConfig
+		feedCategories []string
+		categories     []string
+		feedOut        string
+		out            string
+	}{
+		"none": {},
+		"raw": {
+			feedCategories: []string{"Computers/Software"},
+			categories:     []string{"Site Management/Content Management", "Computers Software"},
+			feedOut:        "Computers/Software",
+			out:            "Site Management/Content Management,Computers Software",
+		},
+		"raw keeps duplicates and separators": {
+			categories: []string{"Go", "Go", "Doe, John"},
+			out:        `Go,Go,"Doe, John"`,
+		},
+		"normalized": {
+			cfg: config.CategoriesConfig{
+				Normalize: true,
+			},
+			categories: []string{" Go ", "go", "", "Kubernetes"},
+			out:        "go,kubernetes",
+		},
+		"synonyms": {
+			cfg: config.CategoriesConfig{
+				Synonyms: map[string]string{
+					"golang": "go",
+				},
+			},
+			categories: []string{"golang", "Golang"},
+			out:        "go,Golang",
+		},
+		"normalized synonyms": {
+			cfg: config.CategoriesConfig{
+				Normalize: true,
+				Synonyms: map[string]string{
+					"Golang": "Go",
+					"k8s":    "kubernetes",
+				},
+			},
+			feedCategories: []string{"K8s"},
+			categories:     []string{"golang", " GoLang", "go", "K8S"},
+			feedOut:        "kubernetes",
+			out:            "go,kubernetes",
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			cfgMsg := config.MessageConfig{
+				Metadata: config.MetadataConfig{
+					KeyCategories:     "categories",
+					KeyFeedCategories: "feedcategories",
+				},
+				Categories: c.cfg,
+			}
+			conv := NewConverter(cfgMsg, slog.Default())
+			item := &rss.Item{
+				Link:       "https://example.com/1",
+				Categories: c.categories,
+			}
+			feed := feeds.NewFeed(&rss.Feed{
+				UpdateURL:  "https://example.com/rss",
+				Categories: c.feedCategories,
+				Items: []*rss.Item{
+					item,
+				},
+			})
+			msg := conv.Convert(feed, item)
+			assert.Equal(t, c.out, msg.Attributes["categories"].GetCeString())
+			assert.Equal(t, c.feedOut, msg.Attributes["feedcategories"].GetCeString())
+		})
+	}
+}
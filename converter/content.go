@@ -0,0 +1,34 @@
+package converter
+
+// The content modes define how the item content and summary are converted.
+const (
+	// ContentModeRaw keeps the content as is.
+	ContentModeRaw = "raw"
+	// ContentModeSanitized keeps only the safe HTML markup.
+	ContentModeSanitized = "sanitized"
+	// ContentModeText converts the HTML to the plain text.
+	ContentModeText = "text"
+	// ContentModeMarkdown converts the HTML to the Markdown.
+	ContentModeMarkdown = "markdown"
+)
+
+const ContentTypeMarkdown = "text/markdown"
+
+// convertContent converts the content of the specified type by the content mode, returns the result and its type.
+// The plain text content is kept as is. The unknown mode is treated as ContentModeRaw. Any mode except the raw one
+// applies the HTML policy first.
+func convertContent(content, contentType, mode string, p htmlPolicy) (result, resultType string) {
+	result, resultType = content, contentType
+	if contentType == ContentTypeText {
+		return
+	}
+	switch mode {
+	case ContentModeSanitized:
+		result, resultType = sanitizeHtml(content, p), ContentTypeHtml
+	case ContentModeText:
+		result, resultType = htmlToText(content, p), ContentTypeText
+	case ContentModeMarkdown:
+		result, resultType = htmlToMarkdown(content, p), ContentTypeMarkdown
+	}
+	return
+}
@@ -0,0 +1,66 @@
+package converter
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestConvertContent(t *testing.T) {
+	cases := map[string]struct {
+		content     string
+		contentType string
+		mode        string
+		out         string
+		outType     string
+	}{
+		"raw": {
+			content:     "<p>Hello, <b>world</b>!</p><script>alert(1)</script>",
+			contentType: ContentTypeHtml,
+			mode:        ContentModeRaw,
+			out:         "<p>Hello, <b>world</b>!</p><script>alert(1)</script>",
+			outType:     ContentTypeHtml,
+		},
+		"sanitized": {
+			content:     "<p>Hello, <b>world</b>!</p><script>alert(1)</script>",
+			contentType: ContentTypeHtml,
+			mode:        ContentModeSanitized,
+			out:         "<p>Hello, <b>world</b>!</p>",
+			outType:     ContentTypeHtml,
+		},
+		"text": {
+			content:     "<p>Hello, <b>world</b>!</p><script>alert(1)</script>",
+			contentType: ContentTypeHtml,
+			mode:        ContentModeText,
+			out:         "Hello, world!",
+			outType:     ContentTypeText,
+		},
+		"markdown": {
+			content:     "<p>Hello, <b>world</b>!</p><script>alert(1)</script>",
+			contentType: ContentTypeXhtml,
+			mode:        ContentModeMarkdown,
+			out:         "Hello, **world**!",
+			outType:     ContentTypeMarkdown,
+		},
+		"plain text is kept": {
+			content:     "Hello, *world* & <others>",
+			contentType: ContentTypeText,
+			mode:        ContentModeMarkdown,
+			out:         "Hello, *world* & <others>",
+			outType:     ContentTypeText,
+		},
+		"unknown mode": {
+			content:     "<p>Hello</p>",
+			contentType: ContentTypeHtml,
+			mode:        "foo",
+			out:         "<p>Hello</p>",
+			outType:     ContentTypeHtml,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			out, outType := convertContent(c.content, c.contentType, c.mode, htmlPolicy{})
+			assert.Equal(t, c.out, out)
+			assert.Equal(t, c.outType, outType)
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package converter
+
+import (
+	"regexp"
+	"strings"
+)
+
+const ContentTypeHtml = "text/html"
+const ContentTypeText = "text/plain"
+const ContentTypeXhtml = "application/xhtml+xml"
+
+const attrDataContentType = "datacontenttype"
+
+var reHtmlMarkup = regexp.MustCompile(`(?i)<!--|<!\[CDATA\[|</?[a-z][a-z0-9]*(\s[^<>]*)?/?>`)
+var reXhtmlDiv = regexp.MustCompile(`^<div\s[^>]*xmlns\s*=\s*["']http://www\.w3\.org/1999/xhtml["']`)
+
+// detectContentType returns the feed item content type: the type declared by the feed when any, e.g. by the Atom
+// content type attribute. Otherwise, the type is sniffed: XHTML when the content is the Atom XHTML div, HTML when the
+// content contains any markup, or the default type otherwise, because the plain text is also valid HTML.
+func detectContentType(content, declaredType, defaultType string) (t string) {
+	trimmed := strings.TrimSpace(content)
+	switch {
+	case declaredType != "":
+		t = declaredType
+	case reXhtmlDiv.MatchString(trimmed):
+		t = ContentTypeXhtml
+	case reHtmlMarkup.MatchString(trimmed):
+		t = ContentTypeHtml
+	default:
+		t = defaultType
+	}
+	return
+}
@@ -0,0 +1,64 @@
+package converter
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDetectContentType(t *testing.T) {
+	cases := map[string]struct {
+		content      string
+		declaredType string
+		defaultType  string
+		t            string
+	}{
+		"plain text keeps the default": {
+			content:     "a < b and c > d",
+			defaultType: ContentTypeText,
+			t:           ContentTypeText,
+		},
+		"plain text keeps the html default": {
+			content:     "Hello, world",
+			defaultType: ContentTypeHtml,
+			t:           ContentTypeHtml,
+		},
+		"html": {
+			content:     `<p>Hello, <a href="https://world.com">world</a></p>`,
+			defaultType: ContentTypeText,
+			t:           ContentTypeHtml,
+		},
+		"html line break": {
+			content:     "Hello,<br/>world",
+			defaultType: ContentTypeText,
+			t:           ContentTypeHtml,
+		},
+		"xhtml": {
+			content: `
+  <div xmlns="http://www.w3.org/1999/xhtml"><p>Hello, world</p></div>`,
+			defaultType: ContentTypeHtml,
+			t:           ContentTypeXhtml,
+		},
+		"declared plain text with markup": {
+			content:      "Use <b> for bold",
+			declaredType: ContentTypeText,
+			defaultType:  ContentTypeHtml,
+			t:            ContentTypeText,
+		},
+		"declared html without markup": {
+			content:      "Hello, world",
+			declaredType: ContentTypeHtml,
+			defaultType:  ContentTypeText,
+			t:            ContentTypeHtml,
+		},
+		"div without namespace": {
+			content:     `<div class="post">Hello, world</div>`,
+			defaultType: ContentTypeText,
+			t:           ContentTypeHtml,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			assert.Equal(t, c.t, detectContentType(c.content, c.declaredType, c.defaultType))
+		})
+	}
+}
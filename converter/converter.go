@@ -1,30 +1,47 @@
 package converter
 
 import (
+	"fmt"
 	"github.com/SlyMarbo/rss"
 	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
-	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
 	"google.golang.org/protobuf/types/known/timestamppb"
+	neturl "net/url"
 	"producer-rss/config"
+	"producer-rss/feeds"
 	"strings"
 	"time"
 )
 
 type Converter interface {
-	Convert(feed *rss.Feed, item *rss.Item) (msg *pb.CloudEvent)
+	Convert(feed *feeds.Feed, item *rss.Item) (msg *pb.CloudEvent)
 }
 
 type converter struct {
 	cfgMsg config.MessageConfig
+	// synonyms are the category synonyms, the keys are normalized when the categories normalization is enabled
+	synonyms map[string]string
+	log      *slog.Logger
 }
 
-func NewConverter(cfgMsg config.MessageConfig) Converter {
+// NewConverter returns the converter of the feed items to the messages. The relative URLs are resolved against the
+// item link or the feed link, the invalid URLs are dropped with the warning logged.
+func NewConverter(cfgMsg config.MessageConfig, log *slog.Logger) Converter {
+	synonyms := map[string]string{}
+	for k, v := range cfgMsg.Categories.Synonyms {
+		if cfgMsg.Categories.Normalize {
+			k = normalizeCategory(k)
+		}
+		synonyms[k] = v
+	}
 	return converter{
-		cfgMsg: cfgMsg,
+		cfgMsg:   cfgMsg,
+		synonyms: synonyms,
+		log:      log,
 	}
 }
 
-func (c converter) Convert(feed *rss.Feed, item *rss.Item) (msg *pb.CloudEvent) {
+func (c converter) Convert(feed *feeds.Feed, item *rss.Item) (msg *pb.CloudEvent) {
 	//
 	var t time.Time
 	switch {
@@ -41,17 +58,31 @@ func (c converter) Convert(feed *rss.Feed, item *rss.Item) (msg *pb.CloudEvent)
 		},
 	}
 	//
+	var feedUrl *neturl.URL
+	if u, ok := resolveUrl(nil, feed.UpdateURL); ok {
+		feedUrl = u
+	}
+	feedLink := c.resolveUrl(feedUrl, feed.Link, feed, item, "feed link")
+	if feedLink == nil {
+		feedLink = feedUrl
+	}
+	itemLink := c.resolveUrl(feedLink, item.Link, feed, item, "link")
+	base := itemLink
+	if base == nil {
+		base = feedLink
+	}
+	//
 	if feed.Author != "" {
-		attrs[c.cfgMsg.Metadata.KeyAuthor] = &pb.CloudEventAttributeValue{
+		attrs[c.cfgMsg.Metadata.KeyFeedAuthor] = &pb.CloudEventAttributeValue{
 			Attr: &pb.CloudEventAttributeValue_CeString{
 				CeString: feed.Author,
 			},
 		}
 	}
-	if len(feed.Categories) > 0 {
+	if categories := joinList(c.categories(feed.Categories)); categories != "" {
 		attrs[c.cfgMsg.Metadata.KeyFeedCategories] = &pb.CloudEventAttributeValue{
 			Attr: &pb.CloudEventAttributeValue_CeString{
-				CeString: strings.Join(feed.Categories, " "),
+				CeString: categories,
 			},
 		}
 	}
@@ -70,10 +101,10 @@ func (c converter) Convert(feed *rss.Feed, item *rss.Item) (msg *pb.CloudEvent)
 				},
 			}
 		}
-		if feed.Image.URL != "" {
+		if u := c.resolveUrl(feedLink, feed.Image.URL, feed, item, "feed image"); u != nil {
 			attrs[c.cfgMsg.Metadata.KeyFeedImageUrl] = &pb.CloudEventAttributeValue{
 				Attr: &pb.CloudEventAttributeValue_CeUri{
-					CeUri: feed.Image.URL,
+					CeUri: u.String(),
 				},
 			}
 		}
@@ -106,20 +137,38 @@ func (c converter) Convert(feed *rss.Feed, item *rss.Item) (msg *pb.CloudEvent)
 			},
 		}
 	}
-	if len(item.Categories) > 0 {
+	authors := feed.Ext(item).Authors
+	switch {
+	case len(authors) > 0:
+	case len(feed.Authors) > 0:
+		// the item has no own author, the feed authors are the item authors too
+		authors = feed.Authors
+	case feed.Author != "":
+		authors = []string{
+			feed.Author,
+		}
+	}
+	if author := joinList(authors); author != "" {
+		attrs[c.cfgMsg.Metadata.KeyAuthor] = &pb.CloudEventAttributeValue{
+			Attr: &pb.CloudEventAttributeValue_CeString{
+				CeString: author,
+			},
+		}
+	}
+	if categories := joinList(c.categories(item.Categories)); categories != "" {
 		attrs[c.cfgMsg.Metadata.KeyCategories] = &pb.CloudEventAttributeValue{
 			Attr: &pb.CloudEventAttributeValue_CeString{
-				CeString: strings.Join(item.Categories, " "),
+				CeString: categories,
 			},
 		}
 	}
 	if item.Image == nil {
 		for _, encl := range item.Enclosures {
 			if strings.HasPrefix(encl.Type, "image/") {
-				if encl.URL != "" {
+				if u := c.resolveUrl(base, encl.URL, feed, item, "enclosure"); u != nil {
 					attrs[c.cfgMsg.Metadata.KeyImageUrl] = &pb.CloudEventAttributeValue{
 						Attr: &pb.CloudEventAttributeValue_CeString{
-							CeString: encl.URL,
+							CeString: u.String(),
 						},
 					}
 				}
@@ -134,18 +183,25 @@ func (c converter) Convert(feed *rss.Feed, item *rss.Item) (msg *pb.CloudEvent)
 				},
 			}
 		}
-		if item.Image.URL != "" {
+		if u := c.resolveUrl(base, item.Image.URL, feed, item, "image"); u != nil {
 			attrs[c.cfgMsg.Metadata.KeyImageUrl] = &pb.CloudEventAttributeValue{
 				Attr: &pb.CloudEventAttributeValue_CeString{
-					CeString: item.Image.URL,
+					CeString: u.String(),
 				},
 			}
 		}
 	}
+	c.convertEnclosure(attrs, feed, item, base)
+	c.convertMedia(attrs, feed, item, base)
+	c.convertPodcast(attrs, feed, item, base)
+	mode := c.contentMode(feed)
 	if item.Summary != "" {
+		summaryType := detectContentType(item.Summary, feed.Ext(item).SummaryType, c.cfgMsg.Content.Type)
+		policy := c.htmlPolicy(feed, item, c.xmlBase(base, feed.Ext(item).SummaryBase, feed, item))
+		summary, _ := convertContent(item.Summary, summaryType, mode, policy)
 		attrs[c.cfgMsg.Metadata.KeySummary] = &pb.CloudEventAttributeValue{
 			Attr: &pb.CloudEventAttributeValue_CeString{
-				CeString: item.Summary,
+				CeString: summary,
 			},
 		}
 	}
@@ -156,17 +212,94 @@ func (c converter) Convert(feed *rss.Feed, item *rss.Item) (msg *pb.CloudEvent)
 			},
 		}
 	}
+	var source string
+	sourceUrl := c.resolveUrl(base, feed.Ext(item).OrigLink, feed, item, "original link")
+	if sourceUrl == nil {
+		sourceUrl = itemLink
+	}
+	if sourceUrl != nil {
+		source = canonicalUrl(sourceUrl, c.cfgMsg.TrackingParams, c.cfgMsg.KeptParamsByFeed[listUrl(feed)]).String()
+		if itemLink != nil && itemLink.String() != source {
+			attrs[c.cfgMsg.Metadata.KeyOrigUrl] = &pb.CloudEventAttributeValue{
+				Attr: &pb.CloudEventAttributeValue_CeUri{
+					CeUri: itemLink.String(),
+				},
+			}
+		}
+	}
 	msg = &pb.CloudEvent{
-		Id:          uuid.NewString(),
+		Id:          newId(c.cfgMsg.IdScheme, feed.Feed, item),
 		SpecVersion: c.cfgMsg.Metadata.SpecVersion,
-		Source:      item.Link,
+		Source:      source,
 		Type:        "com.github.awakari.producer-rss",
 		Attributes:  attrs,
 	}
 	if item.Content != "" {
+		contentType := detectContentType(item.Content, feed.Ext(item).ContentType, c.cfgMsg.Content.Type)
+		policy := c.htmlPolicy(feed, item, c.xmlBase(base, feed.Ext(item).ContentBase, feed, item))
+		content, contentType := convertContent(item.Content, contentType, mode, policy)
 		msg.Data = &pb.CloudEvent_TextData{
-			TextData: item.Content,
+			TextData: content,
+		}
+		attrs[attrDataContentType] = &pb.CloudEventAttributeValue{
+			Attr: &pb.CloudEventAttributeValue_CeString{
+				CeString: contentType,
+			},
 		}
 	}
 	return
 }
+
+// contentMode returns the content mode for the feed, if set, or the default one.
+func (c converter) contentMode(feed *feeds.Feed) (mode string) {
+	mode = c.cfgMsg.Content.ModeByFeed[listUrl(feed)]
+	if mode == "" {
+		mode = c.cfgMsg.Content.Mode
+	}
+	return
+}
+
+// listUrl returns the feed URL the per feed options are set by. It's the listed feed URL, so the options are kept after
+// the feed has moved.
+func listUrl(feed *feeds.Feed) (url string) {
+	url = feed.ListUrl
+	if url == "" {
+		url = feed.UpdateURL
+	}
+	return
+}
+
+// htmlPolicy returns the HTML policy resolving the relative links against the base URL.
+func (c converter) htmlPolicy(feed *feeds.Feed, item *rss.Item, base *neturl.URL) (p htmlPolicy) {
+	p.base = base
+	p.trackers = c.cfgMsg.Content.Trackers
+	p.invalid = func(s string) {
+		c.warnInvalidUrl(s, feed, item, "content")
+	}
+	return
+}
+
+// xmlBase returns the xml:base declared for the item content or summary, if valid, or the item base URL otherwise.
+func (c converter) xmlBase(base *neturl.URL, xmlBase string, feed *feeds.Feed, item *rss.Item) *neturl.URL {
+	if u := c.resolveUrl(nil, xmlBase, feed, item, "xml:base"); u != nil {
+		base = u
+	}
+	return base
+}
+
+// resolveUrl returns the absolute URL or nil when the URL is empty or invalid.
+func (c converter) resolveUrl(base *neturl.URL, s string, feed *feeds.Feed, item *rss.Item, name string) (u *neturl.URL) {
+	if s == "" {
+		return
+	}
+	u, ok := resolveUrl(base, s)
+	if !ok {
+		u = nil
+		c.warnInvalidUrl(s, feed, item, name)
+	}
+	return
+}
+
+func (c converter) warnInvalidUrl(s string, feed *feeds.Feed, item *rss.Item, name string) {
+	c.log.Warn(fmt.Sprintf("converter: dropped invalid %s URL %q, feed: %s, item: %s", name, s, feed.UpdateURL, item.ID))
+}
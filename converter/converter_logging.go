@@ -5,6 +5,7 @@ import (
 	"github.com/SlyMarbo/rss"
 	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
 	"golang.org/x/exp/slog"
+	"producer-rss/feeds"
 )
 
 type converterLogging struct {
@@ -19,7 +20,7 @@ func NewConverterLogging(conv Converter, log *slog.Logger) Converter {
 	}
 }
 
-func (cl converterLogging) Convert(feed *rss.Feed, item *rss.Item) (msg *pb.CloudEvent) {
+func (cl converterLogging) Convert(feed *feeds.Feed, item *rss.Item) (msg *pb.CloudEvent) {
 	msg = cl.conv.Convert(feed, item)
 	cl.log.Debug(fmt.Sprintf("converter.Convert(_, %s): %s", item.ID, msg.Id))
 	return
@@ -0,0 +1,393 @@
+package converter
+
+import (
+	"context"
+	"github.com/SlyMarbo/rss"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+	"io"
+	"net/http"
+	"producer-rss/config"
+	"producer-rss/feeds"
+	"strings"
+	"testing"
+)
+
+func TestConverter_Convert_Urls(t *testing.T) {
+	cfgMsg := config.MessageConfig{
+		Metadata: config.MetadataConfig{
+			KeyFeedImageUrl: "feedimageurl",
+			KeyImageUrl:     "imageurl",
+		},
+		Content: config.ContentConfig{
+			Mode: ContentModeSanitized,
+		},
+	}
+	conv := NewConverter(cfgMsg, slog.Default())
+	cases := map[string]struct {
+		feed         *rss.Feed
+		item         *rss.Item
+		source       string
+		feedImageUrl string
+		imageUrl     string
+		content      string
+	}{
+		"absolute": {
+			feed: &rss.Feed{
+				UpdateURL: "https://feed.com/rss",
+				Link:      "https://feed.com",
+				Image: &rss.Image{
+					URL: "https://cdn.feed.com/logo.png",
+				},
+			},
+			item: &rss.Item{
+				Link: "https://feed.com/news/1.html",
+				Image: &rss.Image{
+					URL: "https://cdn.feed.com/1.png",
+				},
+				Content: `<a href="https://other.com">other</a>`,
+			},
+			source:       "https://feed.com/news/1.html",
+			feedImageUrl: "https://cdn.feed.com/logo.png",
+			imageUrl:     "https://cdn.feed.com/1.png",
+			content:      `<a href="https://other.com">other</a>`,
+		},
+		"relative to the item link": {
+			feed: &rss.Feed{
+				UpdateURL: "https://feed.com/rss",
+				Link:      "https://feed.com/",
+			},
+			item: &rss.Item{
+				Link: "https://feed.com/news/1.html",
+				Enclosures: []*rss.Enclosure{
+					{
+						URL:  "img/1.png",
+						Type: "image/png",
+					},
+				},
+				Content: `<a href="2.html">next</a>`,
+			},
+			source:   "https://feed.com/news/1.html",
+			imageUrl: "https://feed.com/news/img/1.png",
+			content:  `<a href="https://feed.com/news/2.html">next</a>`,
+		},
+		"relative to the feed link": {
+			feed: &rss.Feed{
+				UpdateURL: "https://feed.com/rss",
+				Link:      "/site/",
+				Image: &rss.Image{
+					URL: "logo.png",
+				},
+			},
+			item: &rss.Item{
+				Link: "news/1.html",
+				Image: &rss.Image{
+					URL: "/1.png",
+				},
+			},
+			source:       "https://feed.com/site/news/1.html",
+			feedImageUrl: "https://feed.com/site/logo.png",
+			imageUrl:     "https://feed.com/1.png",
+		},
+		"invalid": {
+			feed: &rss.Feed{
+				UpdateURL: "https://feed.com/rss",
+				Link:      "https://feed.com/",
+			},
+			item: &rss.Item{
+				Link: "https://feed.com/news/%zz",
+				Image: &rss.Image{
+					URL: "http://[::1",
+				},
+				Content: `<a href="https://feed.com/%zz">next</a>`,
+			},
+			content: `<a>next</a>`,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			msg := conv.Convert(feeds.NewFeed(c.feed), c.item)
+			assert.Equal(t, c.source, msg.Source)
+			assert.Equal(t, c.feedImageUrl, msg.Attributes["feedimageurl"].GetCeUri())
+			assert.Equal(t, c.imageUrl, msg.Attributes["imageurl"].GetCeString())
+			assert.Equal(t, c.content, msg.GetTextData())
+		})
+	}
+}
+
+func TestConverter_Convert_Source(t *testing.T) {
+	cfgMsg := config.MessageConfig{
+		TrackingParams: []string{
+			"utm_*",
+			"rss",
+		},
+		KeptParamsByFeed: map[string][]string{
+			"https://site.com/rss?keep": {
+				"rss",
+			},
+		},
+		Metadata: config.MetadataConfig{
+			KeyOrigUrl: "origurl",
+		},
+	}
+	conv := NewConverter(cfgMsg, slog.Default())
+	cases := map[string]struct {
+		feedUrl  string
+		link     string
+		origLink string
+		source   string
+		origUrl  string
+	}{
+		"canonical": {
+			link:   "https://site.com/news/1",
+			source: "https://site.com/news/1",
+		},
+		"tracking params": {
+			link:    "https://Site.com:443/news/1?utm_source=rss",
+			source:  "https://site.com/news/1",
+			origUrl: "https://Site.com:443/news/1?utm_source=rss",
+		},
+		"feedburner": {
+			link:     "https://feedproxy.google.com/~r/site/~3/abc/",
+			origLink: "https://site.com/news/1?utm_medium=feed",
+			source:   "https://site.com/news/1",
+			origUrl:  "https://feedproxy.google.com/~r/site/~3/abc/",
+		},
+		"no link": {
+			origLink: "https://site.com/news/1",
+			source:   "https://site.com/news/1",
+		},
+		"rss param": {
+			link:    "https://site.com/news?rss=1&id=1",
+			source:  "https://site.com/news?id=1",
+			origUrl: "https://site.com/news?rss=1&id=1",
+		},
+		"rss param kept for the feed": {
+			feedUrl: "https://site.com/rss?keep",
+			link:    "https://site.com/news?rss=1&id=1&utm_source=rss",
+			source:  "https://site.com/news?rss=1&id=1",
+			origUrl: "https://site.com/news?rss=1&id=1&utm_source=rss",
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			item := &rss.Item{
+				Link: c.link,
+			}
+			feed := feeds.NewFeed(&rss.Feed{
+				UpdateURL: "https://site.com/rss",
+				Link:      "https://site.com",
+				Items: []*rss.Item{
+					item,
+				},
+			})
+			feed.ListUrl = c.feedUrl
+			feed.SetExt(item, feeds.ItemExt{
+				OrigLink: c.origLink,
+			})
+			msg := conv.Convert(feed, item)
+			assert.Equal(t, c.source, msg.Source)
+			assert.Equal(t, c.origUrl, msg.Attributes["origurl"].GetCeUri())
+		})
+	}
+}
+
+func TestConverter_Convert_Authors(t *testing.T) {
+	cfgMsg := config.MessageConfig{
+		Metadata: config.MetadataConfig{
+			KeyAuthor:     "author",
+			KeyFeedAuthor: "feedauthor",
+		},
+	}
+	conv := NewConverter(cfgMsg, slog.Default())
+	cases := map[string]struct {
+		feedAuthor  string
+		feedAuthors []string
+		authors     []string
+		author      string
+	}{
+		"none": {},
+		"feed author only": {
+			feedAuthor: "arXiv.org",
+			author:     "arXiv.org",
+		},
+		"feed authors only": {
+			feedAuthor: "Doe, John, Jane Roe",
+			feedAuthors: []string{
+				"Doe, John",
+				"Jane Roe",
+			},
+			author: `"Doe, John",Jane Roe`,
+		},
+		"item authors": {
+			feedAuthor: "arXiv.org",
+			authors: []string{
+				"Doe, John",
+				"Jane Roe",
+			},
+			author: `"Doe, John",Jane Roe`,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			item := &rss.Item{
+				Link: "https://arxiv.org/abs/1",
+			}
+			feed := feeds.NewFeed(&rss.Feed{
+				UpdateURL: "https://arxiv.org/rss/cs",
+				Author:    c.feedAuthor,
+				Items: []*rss.Item{
+					item,
+				},
+			})
+			feed.Authors = c.feedAuthors
+			feed.SetExt(item, feeds.ItemExt{
+				Authors: c.authors,
+			})
+			msg := conv.Convert(feed, item)
+			assert.Equal(t, c.author, msg.Attributes["author"].GetCeString())
+			assert.Equal(t, c.feedAuthor, msg.Attributes["feedauthor"].GetCeString())
+		})
+	}
+}
+
+func TestConverter_contentMode(t *testing.T) {
+	cfgMsg := config.MessageConfig{
+		Content: config.ContentConfig{
+			Mode: ContentModeSanitized,
+			ModeByFeed: map[string]string{
+				"https://feed.com/rss": ContentModeMarkdown,
+			},
+		},
+	}
+	conv := NewConverter(cfgMsg, slog.Default()).(converter)
+	cases := map[string]struct {
+		updateUrl string
+		listUrl   string
+		mode      string
+	}{
+		"listed": {
+			updateUrl: "https://feed.com/rss",
+			mode:      ContentModeMarkdown,
+		},
+		"moved": {
+			updateUrl: "https://feed.com/feed.xml",
+			listUrl:   "https://feed.com/rss",
+			mode:      ContentModeMarkdown,
+		},
+		"default": {
+			updateUrl: "https://other.com/rss",
+			listUrl:   "https://other.com/rss",
+			mode:      ContentModeSanitized,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			feed := feeds.NewFeed(&rss.Feed{
+				UpdateURL: c.updateUrl,
+			})
+			feed.ListUrl = c.listUrl
+			assert.Equal(t, c.mode, conv.contentMode(feed))
+		})
+	}
+}
+
+func TestConverter_Convert_AtomContent(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Blog</title>
+  <link href="https://blog.com/"/>
+  <id>urn:blog</id>
+  <updated>2023-05-01T00:00:00Z</updated>
+  <entry>
+    <title>Post 1</title>
+    <id>urn:post1</id>
+    <link href="https://blog.com/posts/1"/>
+    <updated>2023-05-01T00:00:00Z</updated>
+    <content type="html">&lt;p&gt;Hello, &lt;b&gt;world&lt;/b&gt;&lt;script&gt;alert(1)&lt;/script&gt;&lt;/p&gt;</content>
+  </entry>
+</feed>`
+	fr, err := feeds.Fetch(context.TODO(), docClient(doc), "https://blog.com/atom", feeds.Validators{})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(fr.Feed.Items))
+	cases := map[string]struct {
+		mode        string
+		content     string
+		contentType string
+	}{
+		"raw": {
+			mode:        ContentModeRaw,
+			content:     "<p>Hello, <b>world</b><script>alert(1)</script></p>",
+			contentType: ContentTypeHtml,
+		},
+		"sanitized": {
+			mode:        ContentModeSanitized,
+			content:     "<p>Hello, <b>world</b></p>",
+			contentType: ContentTypeHtml,
+		},
+		"text": {
+			mode:        ContentModeText,
+			content:     "Hello, world",
+			contentType: ContentTypeText,
+		},
+		"markdown": {
+			mode:        ContentModeMarkdown,
+			content:     "Hello, **world**",
+			contentType: ContentTypeMarkdown,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			cfgMsg := config.MessageConfig{
+				Content: config.ContentConfig{
+					Mode: c.mode,
+					Type: ContentTypeText,
+				},
+			}
+			msg := NewConverter(cfgMsg, slog.Default()).Convert(fr.Feed, fr.Feed.Items[0])
+			assert.Equal(t, c.content, msg.GetTextData())
+			assert.Equal(t, c.contentType, msg.Attributes[attrDataContentType].GetCeString())
+		})
+	}
+}
+
+func TestConverter_Convert_ContentBase(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xml:base="https://blog.com/">
+  <title>Blog</title>
+  <link href="/"/>
+  <id>urn:blog</id>
+  <updated>2023-05-01T00:00:00Z</updated>
+  <entry>
+    <title>Post 1</title>
+    <id>urn:post1</id>
+    <link href="https://other.com/posts/1"/>
+    <updated>2023-05-01T00:00:00Z</updated>
+    <summary type="html" xml:base="/pages/">&lt;a href="about.html"&gt;About&lt;/a&gt;</summary>
+    <content type="html" xml:base="media/">&lt;p&gt;&lt;a href="1.html"&gt;More&lt;/a&gt;&lt;/p&gt;</content>
+  </entry>
+</feed>`
+	fr, err := feeds.Fetch(context.TODO(), docClient(doc), "https://blog.com/atom", feeds.Validators{})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(fr.Feed.Items))
+	var cfgMsg config.MessageConfig
+	cfgMsg.Content.Mode = ContentModeSanitized
+	cfgMsg.Metadata.KeySummary = "summary"
+	msg := NewConverter(cfgMsg, slog.Default()).Convert(fr.Feed, fr.Feed.Items[0])
+	// the content URLs are resolved against the xml:base, not the item link
+	assert.Equal(t, `<p><a href="https://blog.com/media/1.html">More</a></p>`, msg.GetTextData())
+	assert.Equal(t, `<a href="https://blog.com/pages/about.html">About</a>`, msg.Attributes["summary"].GetCeString())
+}
+
+// docClient responds with the same feed document to any request.
+type docClient string
+
+func (dc docClient) Get(ctx context.Context, url string, v feeds.Validators) (resp *http.Response, err error) {
+	resp = &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(string(dc))),
+	}
+	return
+}
@@ -0,0 +1,568 @@
+package converter
+
+import (
+	"fmt"
+	"golang.org/x/exp/slices"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	neturl "net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// htmlBlockTags are separated from the surrounding text by the line breaks in the plain text and Markdown.
+var htmlBlockTags = map[atom.Atom]bool{
+	atom.Address:    true,
+	atom.Article:    true,
+	atom.Aside:      true,
+	atom.Blockquote: true,
+	atom.Dd:         true,
+	atom.Details:    true,
+	atom.Div:        true,
+	atom.Dl:         true,
+	atom.Dt:         true,
+	atom.Fieldset:   true,
+	atom.Figcaption: true,
+	atom.Figure:     true,
+	atom.Footer:     true,
+	atom.Form:       true,
+	atom.H1:         true,
+	atom.H2:         true,
+	atom.H3:         true,
+	atom.H4:         true,
+	atom.H5:         true,
+	atom.H6:         true,
+	atom.Header:     true,
+	atom.Hr:         true,
+	atom.Li:         true,
+	atom.Main:       true,
+	atom.Nav:        true,
+	atom.Ol:         true,
+	atom.P:          true,
+	atom.Pre:        true,
+	atom.Section:    true,
+	atom.Summary:    true,
+	atom.Table:      true,
+	atom.Tr:         true,
+	atom.Ul:         true,
+}
+
+// htmlSkipTags are dropped together with their contents.
+var htmlSkipTags = map[atom.Atom]bool{
+	atom.Button:   true,
+	atom.Embed:    true,
+	atom.Head:     true,
+	atom.Iframe:   true,
+	atom.Noscript: true,
+	atom.Object:   true,
+	atom.Script:   true,
+	atom.Select:   true,
+	atom.Style:    true,
+	atom.Svg:      true,
+	atom.Template: true,
+	atom.Textarea: true,
+}
+
+// htmlAllowedAttrs are the tags kept by the sanitizer with their allowed attributes, the other tags are unwrapped.
+var htmlAllowedAttrs = map[atom.Atom][]string{
+	atom.A:          {"href", "title"},
+	atom.Abbr:       {"title"},
+	atom.B:          nil,
+	atom.Blockquote: {"cite"},
+	atom.Br:         nil,
+	atom.Code:       nil,
+	atom.Dd:         nil,
+	atom.Del:        nil,
+	atom.Div:        nil,
+	atom.Dl:         nil,
+	atom.Dt:         nil,
+	atom.Em:         nil,
+	atom.Figcaption: nil,
+	atom.Figure:     nil,
+	atom.H1:         nil,
+	atom.H2:         nil,
+	atom.H3:         nil,
+	atom.H4:         nil,
+	atom.H5:         nil,
+	atom.H6:         nil,
+	atom.Hr:         nil,
+	atom.I:          nil,
+	atom.Img:        {"src", "alt", "title", "width", "height"},
+	atom.Ins:        nil,
+	atom.Li:         nil,
+	atom.Mark:       nil,
+	atom.Ol:         {"start"},
+	atom.P:          nil,
+	atom.Pre:        nil,
+	atom.Q:          {"cite"},
+	atom.S:          nil,
+	atom.Small:      nil,
+	atom.Span:       nil,
+	atom.Strong:     nil,
+	atom.Sub:        nil,
+	atom.Sup:        nil,
+	atom.Table:      nil,
+	atom.Tbody:      nil,
+	atom.Td:         {"colspan", "rowspan"},
+	atom.Tfoot:      nil,
+	atom.Th:         {"colspan", "rowspan"},
+	atom.Thead:      nil,
+	atom.Tr:         nil,
+	atom.U:          nil,
+	atom.Ul:         nil,
+}
+
+// htmlUrlAttrs are the attributes containing the URLs, only the safe URL schemes are allowed there.
+var htmlUrlAttrs = map[string]bool{
+	"cite": true,
+	"href": true,
+	"src":  true,
+}
+
+var htmlSafeUrlSchemes = map[string]bool{
+	"":       true,
+	"http":   true,
+	"https":  true,
+	"mailto": true,
+}
+
+var htmlVoidTags = map[atom.Atom]bool{
+	atom.Br:  true,
+	atom.Hr:  true,
+	atom.Img: true,
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"*", `\*`,
+	"_", `\_`,
+	"`", "\\`",
+	"[", `\[`,
+	"]", `\]`,
+)
+
+// markdownUrlEscaper encodes the characters terminating the Markdown link destination.
+var markdownUrlEscaper = strings.NewReplacer(
+	" ", "%20",
+	"(", "%28",
+	")", "%29",
+)
+
+func parseHtml(s string) (nodes []*html.Node, err error) {
+	body := &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	}
+	return html.ParseFragment(strings.NewReader(s), body)
+}
+
+// sanitizeHtml keeps only the allowed tags and attributes. The unsafe URLs (e.g. "javascript:") are dropped.
+func sanitizeHtml(s string, p htmlPolicy) (result string) {
+	nodes, err := p.parse(s)
+	if err != nil {
+		return html.EscapeString(s)
+	}
+	var sb strings.Builder
+	for _, n := range nodes {
+		writeSanitized(&sb, n)
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+func writeSanitized(sb *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		sb.WriteString(html.EscapeString(n.Data))
+	case html.ElementNode:
+		if htmlSkipTags[n.DataAtom] {
+			return
+		}
+		allowedAttrs, allowed := htmlAllowedAttrs[n.DataAtom]
+		if allowed {
+			sb.WriteString("<" + n.Data)
+			for _, a := range n.Attr {
+				if a.Namespace == "" && slices.Contains(allowedAttrs, a.Key) && (!htmlUrlAttrs[a.Key] || safeUrl(a.Val)) {
+					sb.WriteString(fmt.Sprintf(` %s="%s"`, a.Key, html.EscapeString(a.Val)))
+				}
+			}
+			sb.WriteString(">")
+			if htmlVoidTags[n.DataAtom] {
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			writeSanitized(sb, c)
+		}
+		if allowed {
+			sb.WriteString("</" + n.Data + ">")
+		}
+	}
+}
+
+func safeUrl(s string) (ok bool) {
+	u, err := neturl.Parse(strings.TrimSpace(s))
+	if err == nil {
+		ok = htmlSafeUrlSchemes[strings.ToLower(u.Scheme)]
+	}
+	return
+}
+
+// htmlToText converts the HTML to the plain text: the entities are decoded, the block elements are separated by the
+// line breaks, the whitespace is collapsed except the preformatted text.
+func htmlToText(s string, p htmlPolicy) (result string) {
+	nodes, err := p.parse(s)
+	if err != nil {
+		return s
+	}
+	tb := &textBuilder{}
+	for _, n := range nodes {
+		tb.writeText(n)
+	}
+	return tb.String()
+}
+
+// htmlToMarkdown converts the HTML to the Markdown, the unsupported elements are converted to the plain text.
+func htmlToMarkdown(s string, p htmlPolicy) (result string) {
+	nodes, err := p.parse(s)
+	if err != nil {
+		return s
+	}
+	tb := &textBuilder{
+		markdown: true,
+	}
+	for _, n := range nodes {
+		tb.writeMarkdown(n)
+	}
+	return tb.String()
+}
+
+// textBuilder writes the plain text or Markdown collapsing the whitespace and the line breaks.
+type textBuilder struct {
+	sb       strings.Builder
+	markdown bool
+	// breaks is the number of the line breaks to write before the next word
+	breaks int
+	// space is true when the whitespace should be written before the next word
+	space bool
+	// glue is true when the next word should be written without the whitespace, e.g. after the opening "**"
+	glue bool
+	// prefix is written at the beginning of every line, e.g. "> " inside the Markdown quote
+	prefix    string
+	lineStart bool
+	pre       bool
+	// verbatim is true when the Markdown special characters should not be escaped, e.g. inside the inline code
+	verbatim bool
+	// lists contains the next item number for every enclosing list, zero for the unordered lists
+	lists []int
+}
+
+func (tb *textBuilder) String() string {
+	return strings.TrimSpace(tb.sb.String())
+}
+
+// block requests at least the specified number of the line breaks before the next word.
+func (tb *textBuilder) block(breaks int) {
+	// nothing to do at the start of the document or right after the written line breaks
+	if tb.sb.Len() > 0 && !tb.lineStart && breaks > tb.breaks {
+		tb.breaks = breaks
+	}
+	tb.space = false
+}
+
+// flush writes the pending line breaks and the line prefix before the next word.
+func (tb *textBuilder) flush() {
+	tb.flushBreaks()
+	if tb.lineStart || tb.sb.Len() == 0 {
+		tb.sb.WriteString(tb.prefix)
+		tb.lineStart = false
+		tb.space = false
+	}
+}
+
+// word writes the word separated by the whitespace from the previous one, if necessary.
+func (tb *textBuilder) word(w string) {
+	tb.flush()
+	if tb.space && !tb.glue {
+		tb.sb.WriteString(" ")
+	}
+	tb.space = false
+	tb.glue = false
+	tb.sb.WriteString(w)
+}
+
+// attach writes the string right after the previous word, e.g. the closing "**".
+func (tb *textBuilder) attach(s string) {
+	if tb.sb.Len() > 0 {
+		tb.sb.WriteString(s)
+	}
+	tb.glue = false
+}
+
+func (tb *textBuilder) text(s string) {
+	if s == "" {
+		return
+	}
+	if tb.pre {
+		tb.flush()
+		tb.sb.WriteString(strings.ReplaceAll(s, "\n", "\n"+tb.prefix))
+		return
+	}
+	if r, _ := utf8.DecodeRuneInString(s); unicode.IsSpace(r) {
+		tb.space = true
+	}
+	for _, w := range strings.Fields(s) {
+		if tb.markdown && !tb.verbatim {
+			w = markdownEscaper.Replace(w)
+		}
+		tb.word(w)
+		tb.space = true
+	}
+	if r, _ := utf8.DecodeLastRuneInString(s); !unicode.IsSpace(r) {
+		tb.space = false
+	}
+}
+
+func (tb *textBuilder) writeText(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		tb.text(n.Data)
+	case html.ElementNode:
+		if htmlSkipTags[n.DataAtom] {
+			return
+		}
+		switch {
+		case n.DataAtom == atom.Br:
+			tb.block(1)
+		case n.DataAtom == atom.P, n.DataAtom == atom.Hr, n.DataAtom == atom.Pre, n.DataAtom == atom.Blockquote,
+			n.DataAtom == atom.H1, n.DataAtom == atom.H2, n.DataAtom == atom.H3,
+			n.DataAtom == atom.H4, n.DataAtom == atom.H5, n.DataAtom == atom.H6:
+			tb.block(2)
+		case htmlBlockTags[n.DataAtom]:
+			tb.block(1)
+		case n.DataAtom == atom.Td, n.DataAtom == atom.Th:
+			tb.space = true
+		}
+		pre := tb.pre
+		tb.pre = pre || n.DataAtom == atom.Pre
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			tb.writeText(c)
+		}
+		tb.pre = pre
+		switch {
+		case n.DataAtom == atom.P, n.DataAtom == atom.Pre, n.DataAtom == atom.Blockquote,
+			n.DataAtom == atom.H1, n.DataAtom == atom.H2, n.DataAtom == atom.H3,
+			n.DataAtom == atom.H4, n.DataAtom == atom.H5, n.DataAtom == atom.H6:
+			tb.block(2)
+		case htmlBlockTags[n.DataAtom]:
+			tb.block(1)
+		case n.DataAtom == atom.Td, n.DataAtom == atom.Th:
+			tb.space = true
+		}
+	}
+}
+
+func (tb *textBuilder) writeMarkdown(n *html.Node) {
+	if n.Type == html.TextNode {
+		tb.text(n.Data)
+		return
+	}
+	if n.Type != html.ElementNode || htmlSkipTags[n.DataAtom] {
+		return
+	}
+	switch n.DataAtom {
+	case atom.Br:
+		tb.attach("  ")
+		tb.block(1)
+	case atom.Hr:
+		tb.block(2)
+		tb.word("---")
+		tb.block(2)
+	case atom.Img:
+		if src := attrVal(n, "src"); src != "" && safeUrl(src) {
+			tb.word(fmt.Sprintf("![%s](%s)", markdownEscaper.Replace(attrVal(n, "alt")), markdownUrl(src)))
+		}
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		tb.block(2)
+		tb.word(strings.Repeat("#", int(n.Data[1]-'0')))
+		tb.space = true
+		tb.writeMarkdownChildren(n)
+		tb.block(2)
+	case atom.P:
+		tb.block(2)
+		tb.writeMarkdownChildren(n)
+		tb.block(2)
+	case atom.B, atom.Strong:
+		tb.writeMarkdownEnclosed(n, "**")
+	case atom.I, atom.Em:
+		tb.writeMarkdownEnclosed(n, "_")
+	case atom.Del, atom.S:
+		tb.writeMarkdownEnclosed(n, "~~")
+	case atom.Code:
+		if tb.pre {
+			tb.writeMarkdownChildren(n)
+		} else {
+			code := textContent(n)
+			fence := markdownFence(code, 1)
+			opening, closing := fence, fence
+			// the code starting or ending with the backtick is separated from the fence by the space
+			if trimmed := strings.TrimSpace(code); strings.HasPrefix(trimmed, "`") || strings.HasSuffix(trimmed, "`") {
+				opening += " "
+				closing = " " + closing
+			}
+			tb.word(opening)
+			tb.glue = true
+			tb.verbatim = true
+			tb.text(code)
+			tb.verbatim = false
+			tb.attach(closing)
+		}
+	case atom.A:
+		href := attrVal(n, "href")
+		if href == "" || !safeUrl(href) {
+			tb.writeMarkdownChildren(n)
+		} else {
+			tb.word("[")
+			tb.glue = true
+			tb.writeMarkdownChildren(n)
+			tb.attach(fmt.Sprintf("](%s)", markdownUrl(href)))
+		}
+	case atom.Pre:
+		fence := markdownFence(textContent(n), 3)
+		tb.block(2)
+		tb.word(fence)
+		tb.block(1)
+		tb.pre = true
+		tb.writeMarkdownChildren(n)
+		tb.pre = false
+		tb.block(1)
+		tb.word(fence)
+		tb.block(2)
+	case atom.Blockquote:
+		// the line breaks around the quote are written with the outer prefix
+		tb.block(2)
+		tb.flushBreaks()
+		prefix := tb.prefix
+		tb.prefix += "> "
+		tb.writeMarkdownChildren(n)
+		tb.block(2)
+		tb.prefix = prefix
+	case atom.Ul, atom.Ol:
+		tb.block(1)
+		next := 0
+		if n.DataAtom == atom.Ol {
+			next = 1
+		}
+		tb.lists = append(tb.lists, next)
+		tb.writeMarkdownChildren(n)
+		tb.lists = tb.lists[:len(tb.lists)-1]
+		tb.block(1)
+	case atom.Li:
+		tb.block(1)
+		marker := "-"
+		if depth := len(tb.lists); depth > 0 {
+			if tb.lists[depth-1] > 0 {
+				marker = fmt.Sprintf("%d.", tb.lists[depth-1])
+				tb.lists[depth-1]++
+			}
+			marker = strings.Repeat("  ", depth-1) + marker
+		}
+		tb.word(marker)
+		tb.space = true
+		tb.writeMarkdownChildren(n)
+		tb.block(1)
+	default:
+		switch {
+		case htmlBlockTags[n.DataAtom]:
+			tb.block(1)
+			tb.writeMarkdownChildren(n)
+			tb.block(1)
+		case n.DataAtom == atom.Td, n.DataAtom == atom.Th:
+			tb.space = true
+			tb.writeMarkdownChildren(n)
+			tb.space = true
+		default:
+			tb.writeMarkdownChildren(n)
+		}
+	}
+}
+
+func (tb *textBuilder) writeMarkdownChildren(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		tb.writeMarkdown(c)
+	}
+}
+
+func (tb *textBuilder) writeMarkdownEnclosed(n *html.Node, marker string) {
+	if strings.TrimSpace(textContent(n)) == "" {
+		tb.writeMarkdownChildren(n)
+		return
+	}
+	tb.word(marker)
+	tb.glue = true
+	tb.writeMarkdownChildren(n)
+	space := tb.space
+	tb.attach(marker)
+	tb.space = space
+}
+
+// flushBreaks writes the pending line breaks, if any, without writing the line prefix.
+func (tb *textBuilder) flushBreaks() {
+	if tb.breaks > 0 && tb.sb.Len() > 0 {
+		for i := 0; i < tb.breaks; i++ {
+			if i > 0 {
+				tb.sb.WriteString(strings.TrimRight(tb.prefix, " "))
+			}
+			tb.sb.WriteString("\n")
+		}
+		tb.breaks = 0
+		tb.space = false
+		tb.lineStart = true
+	}
+}
+
+func attrVal(n *html.Node, key string) (val string) {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == key {
+			val = strings.TrimSpace(a.Val)
+			break
+		}
+	}
+	return
+}
+
+// markdownUrl returns the URL safe to be used as the Markdown link destination.
+func markdownUrl(u string) string {
+	return markdownUrlEscaper.Replace(strings.TrimSpace(u))
+}
+
+// markdownFence returns the backticks fence longer than any backticks run in the code, at least the min length.
+func markdownFence(code string, minLen int) string {
+	var run, longest int
+	for _, r := range code {
+		if r == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	if longest >= minLen {
+		minLen = longest + 1
+	}
+	return strings.Repeat("`", minLen)
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sb.WriteString(textContent(c))
+	}
+	return sb.String()
+}
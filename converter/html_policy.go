@@ -0,0 +1,139 @@
+package converter
+
+import (
+	"golang.org/x/exp/slices"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	neturl "net/url"
+	"strconv"
+	"strings"
+)
+
+// htmlPolicy removes the unsafe and tracking elements from the parsed HTML before it's converted by the content mode.
+type htmlPolicy struct {
+	// base is the URL to resolve the relative links against, nil when unknown
+	base *neturl.URL
+	// trackers are the tracker domains, the subdomains are matched too
+	trackers []string
+	// invalid is called for every invalid URL dropped, if set
+	invalid func(s string)
+}
+
+// htmlTrackingAttrs are the attributes loading the resource by the URL, the element is removed when it's a tracker.
+var htmlTrackingAttrs = []string{
+	"src",
+	"data-src",
+}
+
+// parse parses the HTML fragment and cleans it up.
+func (p htmlPolicy) parse(s string) (nodes []*html.Node, err error) {
+	nodes, err = parseHtml(s)
+	if err == nil {
+		var kept []*html.Node
+		for _, n := range nodes {
+			if !p.drop(n) {
+				p.clean(n)
+				kept = append(kept, n)
+			}
+		}
+		nodes = kept
+	}
+	return
+}
+
+// clean removes the dropped descendants of the node, the unsafe URLs and the links to the trackers. The relative URLs
+// are resolved against the base URL.
+func (p htmlPolicy) clean(n *html.Node) {
+	var attrs []html.Attribute
+	for _, a := range n.Attr {
+		if htmlUrlAttrs[a.Key] {
+			u, ok := p.resolve(a.Val)
+			if !ok || (a.Key == "href" && p.tracker(u)) {
+				// the link to the tracker is unwrapped
+				continue
+			}
+			a.Val = u.String()
+		}
+		attrs = append(attrs, a)
+	}
+	n.Attr = attrs
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if p.drop(c) {
+			n.RemoveChild(c)
+		} else {
+			p.clean(c)
+		}
+		c = next
+	}
+}
+
+// drop returns true when the element should be removed together with its contents: the skipped tags, the tracking
+// pixels and the resources loaded from the tracker domains.
+func (p htmlPolicy) drop(n *html.Node) (ok bool) {
+	if n.Type != html.ElementNode {
+		return
+	}
+	switch {
+	case htmlSkipTags[n.DataAtom]:
+		ok = true
+	case n.DataAtom == atom.Img && isPixel(n):
+		ok = true
+	default:
+		for _, a := range n.Attr {
+			if a.Namespace == "" && slices.Contains(htmlTrackingAttrs, a.Key) {
+				if u, err := neturl.Parse(strings.TrimSpace(a.Val)); err == nil && p.tracker(u) {
+					ok = true
+					break
+				}
+			}
+		}
+	}
+	return
+}
+
+// resolve returns the absolute URL when the base URL is known, false when the URL is invalid or unsafe.
+func (p htmlPolicy) resolve(s string) (u *neturl.URL, ok bool) {
+	u, err := neturl.Parse(strings.TrimSpace(s))
+	switch {
+	case err != nil:
+		if p.invalid != nil {
+			p.invalid(s)
+		}
+	case htmlSafeUrlSchemes[strings.ToLower(u.Scheme)]:
+		ok = true
+		if p.base != nil && !u.IsAbs() {
+			u = p.base.ResolveReference(u)
+		}
+	}
+	return
+}
+
+func (p htmlPolicy) tracker(u *neturl.URL) (ok bool) {
+	host := strings.ToLower(u.Hostname())
+	if host == "" && p.base != nil && !u.IsAbs() {
+		host = strings.ToLower(p.base.Hostname())
+	}
+	for _, d := range p.trackers {
+		d = strings.ToLower(d)
+		if host == d || strings.HasSuffix(host, "."+d) {
+			ok = true
+			break
+		}
+	}
+	return
+}
+
+// isPixel returns true for the image not larger than 1x1, usually used for tracking.
+func isPixel(n *html.Node) bool {
+	w, okW := pixels(attrVal(n, "width"))
+	h, okH := pixels(attrVal(n, "height"))
+	return okW && okH && w <= 1 && h <= 1
+}
+
+func pixels(s string) (px int, ok bool) {
+	var err error
+	px, err = strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(s), "px"))
+	ok = err == nil
+	return
+}
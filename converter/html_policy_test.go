@@ -0,0 +1,65 @@
+package converter
+
+import (
+	"github.com/stretchr/testify/assert"
+	neturl "net/url"
+	"testing"
+)
+
+func TestHtmlPolicy(t *testing.T) {
+	base, _ := neturl.Parse("https://site.com/news/item1.html")
+	p := htmlPolicy{
+		base: base,
+		trackers: []string{
+			"doubleclick.net",
+			"pixel.wp.com",
+		},
+	}
+	cases := map[string]struct {
+		in        string
+		sanitized string
+		markdown  string
+	}{
+		"iframe and event handler": {
+			in:        `<p onmouseover="steal()">Hello</p><iframe src="https://evil.com"></iframe>`,
+			sanitized: `<p>Hello</p>`,
+			markdown:  "Hello",
+		},
+		"tracking pixel": {
+			in:        `<p>Hello<img src="https://feeds.feedburner.com/~r/site/~4/abc" width="1" height="1px"></p>`,
+			sanitized: `<p>Hello</p>`,
+			markdown:  "Hello",
+		},
+		"small image is kept": {
+			in:        `<img src="https://site.com/icon.png" width="16" height="16">`,
+			sanitized: `<img src="https://site.com/icon.png" width="16" height="16">`,
+			markdown:  "![](https://site.com/icon.png)",
+		},
+		"tracker image": {
+			in:        `<p>Hello <img src="https://ad.doubleclick.net/ad.png"><img src="//pixel.wp.com/g.gif"></p>`,
+			sanitized: `<p>Hello </p>`,
+			markdown:  "Hello",
+		},
+		"tracker link is unwrapped": {
+			in:        `<a href="https://ad.doubleclick.net/click?x=1">Buy</a>`,
+			sanitized: `<a>Buy</a>`,
+			markdown:  "Buy",
+		},
+		"relative links": {
+			in:        `<a href="/about">About</a> <a href="item2.html#top">Next</a> <img src="img/1.png" alt="pic">`,
+			sanitized: `<a href="https://site.com/about">About</a> <a href="https://site.com/news/item2.html#top">Next</a> <img src="https://site.com/news/img/1.png" alt="pic">`,
+			markdown:  "[About](https://site.com/about) [Next](https://site.com/news/item2.html#top) ![pic](https://site.com/news/img/1.png)",
+		},
+		"unsafe link": {
+			in:        `<a href=" javascript:alert(1)">click</a>`,
+			sanitized: `<a>click</a>`,
+			markdown:  "click",
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			assert.Equal(t, c.sanitized, sanitizeHtml(c.in, p))
+			assert.Equal(t, c.markdown, htmlToMarkdown(c.in, p))
+		})
+	}
+}
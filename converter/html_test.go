@@ -0,0 +1,133 @@
+package converter
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSanitizeHtml(t *testing.T) {
+	cases := map[string]struct {
+		in  string
+		out string
+	}{
+		"plain text": {
+			in:  "Tom & Jerry",
+			out: "Tom &amp; Jerry",
+		},
+		"allowed markup": {
+			in:  `<p class="lead">Hello, <a href="https://world.com" onclick="alert(1)">world</a>!</p>`,
+			out: `<p>Hello, <a href="https://world.com">world</a>!</p>`,
+		},
+		"script is dropped": {
+			in:  `<p>Hello</p><script>alert(1)</script><style>p {}</style>`,
+			out: `<p>Hello</p>`,
+		},
+		"unknown tags are unwrapped": {
+			in:  `<font color="red">Hello</font>, <custom>world</custom>`,
+			out: `Hello, world`,
+		},
+		"unsafe url": {
+			in:  `<a href="javascript:alert(1)">click</a><img src="data:image/png;base64,AAAA" alt="x">`,
+			out: `<a>click</a><img alt="x">`,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			assert.Equal(t, c.out, sanitizeHtml(c.in, htmlPolicy{}))
+		})
+	}
+}
+
+func TestHtmlToText(t *testing.T) {
+	cases := map[string]struct {
+		in  string
+		out string
+	}{
+		"plain text": {
+			in:  "  Hello,\n  world  ",
+			out: "Hello, world",
+		},
+		"entities": {
+			in:  "Tom &amp; Jerry&nbsp;&mdash; &#8220;cartoon&#8221;",
+			out: "Tom & Jerry — “cartoon”",
+		},
+		"blocks": {
+			in:  "<h1>Title</h1><p>Paragraph <b>one</b>.</p><p>Paragraph two<br>continued</p><ul><li>item 1</li><li>item 2</li></ul>",
+			out: "Title\n\nParagraph one.\n\nParagraph two\ncontinued\n\nitem 1\nitem 2",
+		},
+		"inline markup keeps the spaces": {
+			in:  "Hello, <a href=\"https://world.com\">world</a>!",
+			out: "Hello, world!",
+		},
+		"preformatted": {
+			in:  "<p>Code:</p><pre>if a {\n  b()\n}</pre>",
+			out: "Code:\n\nif a {\n  b()\n}",
+		},
+		"script is dropped": {
+			in:  "Hello<script>alert(1)</script>",
+			out: "Hello",
+		},
+		"table cells": {
+			in:  "<table><tr><td>a</td><td>b</td></tr><tr><td>c</td><td>d</td></tr></table>",
+			out: "a b\nc d",
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			assert.Equal(t, c.out, htmlToText(c.in, htmlPolicy{}))
+		})
+	}
+}
+
+func TestHtmlToMarkdown(t *testing.T) {
+	cases := map[string]struct {
+		in  string
+		out string
+	}{
+		"plain text": {
+			in:  "Hello, *world*",
+			out: `Hello, \*world\*`,
+		},
+		"headings and paragraphs": {
+			in:  "<h2>Title</h2><p>Paragraph <strong>one </strong>and <em>two</em>.</p>",
+			out: "## Title\n\nParagraph **one** and _two_.",
+		},
+		"link and image": {
+			in:  `<p>See <a href="https://world.com/a_b">the world</a> <img src="https://world.com/i.png" alt="pic"></p>`,
+			out: "See [the world](https://world.com/a_b) ![pic](https://world.com/i.png)",
+		},
+		"link and image with parentheses and spaces": {
+			in:  `<a href="https://world.com/a_(b) c">the world</a> <img src="https://world.com/i (1).png" alt="pic">`,
+			out: "[the world](https://world.com/a_%28b%29%20c) ![pic](https://world.com/i%20%281%29.png)",
+		},
+		"unsafe link": {
+			in:  `<a href="javascript:alert(1)">click</a>`,
+			out: "click",
+		},
+		"lists": {
+			in:  "<ul><li>one</li><li>two<ol><li>first</li><li>second</li></ol></li></ul>",
+			out: "- one\n- two\n  1. first\n  2. second",
+		},
+		"quote": {
+			in:  "<p>Said:</p><blockquote><p>Hello</p><p>world</p></blockquote><p>End</p>",
+			out: "Said:\n\n> Hello\n>\n> world\n\nEnd",
+		},
+		"code": {
+			in:  "<p>Call <code>do_it()</code>:</p><pre><code>do_it()\ndone()</code></pre>",
+			out: "Call `do_it()`:\n\n```\ndo_it()\ndone()\n```",
+		},
+		"code with backticks": {
+			in:  "<p>Use <code>a `b` c</code> and <code>`x`</code>:</p><pre>```\ncode\n```</pre>",
+			out: "Use ``a `b` c`` and `` `x` ``:\n\n````\n```\ncode\n```\n````",
+		},
+		"line break": {
+			in:  "one<br>two",
+			out: "one  \ntwo",
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			assert.Equal(t, c.out, htmlToMarkdown(c.in, htmlPolicy{}))
+		})
+	}
+}
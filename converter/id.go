@@ -0,0 +1,44 @@
+package converter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/SlyMarbo/rss"
+	"github.com/google/uuid"
+)
+
+// The message id schemes.
+const (
+	// IdSchemeUuid5 is the name based UUID (version 5) derived from the feed and the item identity.
+	IdSchemeUuid5 = "uuid5"
+	// IdSchemeSha256 is the hex encoded SHA-256 hash of the feed and the item identity.
+	IdSchemeSha256 = "sha256"
+	// IdSchemeRandom is the random UUID (version 4), the same item gets a new id every time.
+	IdSchemeRandom = "random"
+)
+
+// newId returns the message id derived from the feed URL and the item guid, link or title, whichever is present first.
+// So the same item always gets the same id and the consumers may recognize the re-sent duplicates. Falls back to the
+// random id when the item has neither guid, link nor title. The unknown scheme is treated as IdSchemeUuid5.
+func newId(scheme string, feed *rss.Feed, item *rss.Item) (id string) {
+	itemKey := item.ID
+	if itemKey == "" {
+		itemKey = item.Link
+	}
+	if itemKey == "" {
+		itemKey = item.Title
+	}
+	if itemKey == "" || scheme == IdSchemeRandom {
+		return uuid.NewString()
+	}
+	// the separator makes the concatenation unambiguous, it can't be a part of the URL
+	name := []byte(feed.UpdateURL + "\n" + itemKey)
+	switch scheme {
+	case IdSchemeSha256:
+		sum := sha256.Sum256(name)
+		id = hex.EncodeToString(sum[:])
+	default:
+		id = uuid.NewSHA1(uuid.NameSpaceURL, name).String()
+	}
+	return
+}
@@ -0,0 +1,79 @@
+package converter
+
+import (
+	"github.com/SlyMarbo/rss"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewId(t *testing.T) {
+	feed := &rss.Feed{
+		UpdateURL: "https://feed.com/rss",
+	}
+	otherFeed := &rss.Feed{
+		UpdateURL: "https://other.com/rss",
+	}
+	cases := map[string]struct {
+		scheme string
+		item   *rss.Item
+		stable bool
+		len    int
+	}{
+		"uuid5 by guid": {
+			scheme: IdSchemeUuid5,
+			item: &rss.Item{
+				ID:    "guid0",
+				Link:  "https://feed.com/item0",
+				Title: "title0",
+			},
+			stable: true,
+			len:    36,
+		},
+		"uuid5 by link": {
+			scheme: IdSchemeUuid5,
+			item: &rss.Item{
+				Link: "https://feed.com/item0",
+			},
+			stable: true,
+			len:    36,
+		},
+		"sha256 by title": {
+			scheme: IdSchemeSha256,
+			item: &rss.Item{
+				Title: "title0",
+			},
+			stable: true,
+			len:    64,
+		},
+		"unknown scheme": {
+			scheme: "foo",
+			item: &rss.Item{
+				ID: "guid0",
+			},
+			stable: true,
+			len:    36,
+		},
+		"random": {
+			scheme: IdSchemeRandom,
+			item: &rss.Item{
+				ID: "guid0",
+			},
+			len: 36,
+		},
+		"no identity": {
+			scheme: IdSchemeSha256,
+			item:   &rss.Item{},
+			len:    36,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			id := newId(c.scheme, feed, c.item)
+			assert.Equal(t, c.len, len(id))
+			assert.Equal(t, c.stable, id == newId(c.scheme, feed, c.item))
+			assert.NotEqual(t, id, newId(c.scheme, otherFeed, c.item))
+		})
+	}
+	// the same item key gives the same id regardless of the key source
+	assert.Equal(t, newId(IdSchemeUuid5, feed, &rss.Item{ID: "https://feed.com/item0"}), newId(IdSchemeUuid5, feed, &rss.Item{Link: "https://feed.com/item0"}))
+}
@@ -0,0 +1,26 @@
+package converter
+
+import (
+	"encoding/csv"
+	"strings"
+)
+
+// joinList encodes the list of the values into the single attribute value losslessly: the values are comma-separated,
+// the value containing the comma, the quote or the line break is quoted as in CSV. The single plain value is kept as is.
+// The blank values are dropped, so the result is empty when there are no other values.
+func joinList(values []string) string {
+	var nonBlank []string
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			nonBlank = append(nonBlank, v)
+		}
+	}
+	if len(nonBlank) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+	_ = w.Write(nonBlank)
+	w.Flush()
+	return strings.TrimSuffix(sb.String(), "\n")
+}
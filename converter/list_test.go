@@ -0,0 +1,47 @@
+package converter
+
+import (
+	"encoding/csv"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestJoinList(t *testing.T) {
+	cases := map[string]struct {
+		in     []string
+		out    string
+		values []string
+	}{
+		"single": {
+			in:  []string{"John Doe"},
+			out: "John Doe",
+		},
+		"multiple": {
+			in:  []string{"John Doe", "Jane Roe"},
+			out: "John Doe,Jane Roe",
+		},
+		"special chars": {
+			in:  []string{"Doe, John", `"Jane" Roe`, "a\nb"},
+			out: "\"Doe, John\",\"\"\"Jane\"\" Roe\",\"a\nb\"",
+		},
+		"blank values": {
+			in:     []string{"", "John Doe", " ", "Jane Roe"},
+			out:    "John Doe,Jane Roe",
+			values: []string{"John Doe", "Jane Roe"},
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			out := joinList(c.in)
+			assert.Equal(t, c.out, out)
+			// lossless except the blank values
+			values, err := csv.NewReader(strings.NewReader(out)).Read()
+			assert.Nil(t, err)
+			if c.values == nil {
+				c.values = c.in
+			}
+			assert.Equal(t, c.values, values)
+		})
+	}
+}
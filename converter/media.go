@@ -0,0 +1,65 @@
+package converter
+
+import (
+	"github.com/SlyMarbo/rss"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"math"
+	neturl "net/url"
+	"producer-rss/feeds"
+	"time"
+)
+
+// convertMedia sets the Media RSS attributes of the item. The duration is in seconds. The views count is limited by
+// the max value of the Cloud Event integer attribute.
+func (c converter) convertMedia(attrs map[string]*pb.CloudEventAttributeValue, feed *feeds.Feed, item *rss.Item, base *neturl.URL) {
+	m := feed.Ext(item).Media
+	if u := c.resolveUrl(base, m.ThumbnailUrl, feed, item, "media thumbnail"); u != nil {
+		attrs[c.cfgMsg.Metadata.KeyMediaThumbnailUrl] = &pb.CloudEventAttributeValue{
+			Attr: &pb.CloudEventAttributeValue_CeUri{
+				CeUri: u.String(),
+			},
+		}
+	}
+	if m.Description != "" {
+		attrs[c.cfgMsg.Metadata.KeyMediaDescription] = &pb.CloudEventAttributeValue{
+			Attr: &pb.CloudEventAttributeValue_CeString{
+				CeString: m.Description,
+			},
+		}
+	}
+	if m.Duration > 0 {
+		attrs[c.cfgMsg.Metadata.KeyMediaDuration] = &pb.CloudEventAttributeValue{
+			Attr: &pb.CloudEventAttributeValue_CeInteger{
+				CeInteger: clampInt32(uint64(m.Duration.Round(time.Second) / time.Second)),
+			},
+		}
+	}
+	if m.Views > 0 {
+		attrs[c.cfgMsg.Metadata.KeyMediaViews] = &pb.CloudEventAttributeValue{
+			Attr: &pb.CloudEventAttributeValue_CeInteger{
+				CeInteger: clampInt32(m.Views),
+			},
+		}
+	}
+	if m.Rating != "" {
+		attrs[c.cfgMsg.Metadata.KeyMediaRating] = &pb.CloudEventAttributeValue{
+			Attr: &pb.CloudEventAttributeValue_CeString{
+				CeString: m.Rating,
+			},
+		}
+	}
+	if m.VideoId != "" {
+		attrs[c.cfgMsg.Metadata.KeyMediaVideoId] = &pb.CloudEventAttributeValue{
+			Attr: &pb.CloudEventAttributeValue_CeString{
+				CeString: m.VideoId,
+			},
+		}
+	}
+}
+
+func clampInt32(n uint64) int32 {
+	if n > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int32(n)
+}
@@ -0,0 +1,102 @@
+package converter
+
+import (
+	"github.com/SlyMarbo/rss"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/slog"
+	"math"
+	"producer-rss/config"
+	"producer-rss/feeds"
+	"testing"
+	"time"
+)
+
+func TestConverter_Convert_Media(t *testing.T) {
+	cfgMsg := config.MessageConfig{
+		Metadata: config.MetadataConfig{
+			KeyMediaDescription:  "mediadescription",
+			KeyMediaDuration:     "mediaduration",
+			KeyMediaRating:       "mediarating",
+			KeyMediaThumbnailUrl: "mediathumbnailurl",
+			KeyMediaVideoId:      "mediavideoid",
+			KeyMediaViews:        "mediaviews",
+		},
+	}
+	conv := NewConverter(cfgMsg, slog.Default())
+	cases := map[string]struct {
+		media feeds.ItemMedia
+		attrs map[string]*pb.CloudEventAttributeValue
+	}{
+		"none": {
+			attrs: map[string]*pb.CloudEventAttributeValue{},
+		},
+		"youtube": {
+			media: feeds.ItemMedia{
+				ThumbnailUrl: "/vi/abc/hqdefault.jpg",
+				Description:  "Video description",
+				Duration:     123500 * time.Millisecond,
+				Views:        3_000_000_000,
+				Rating:       "4.85",
+				VideoId:      "abc",
+			},
+			attrs: map[string]*pb.CloudEventAttributeValue{
+				"mediathumbnailurl": {
+					Attr: &pb.CloudEventAttributeValue_CeUri{
+						CeUri: "https://www.youtube.com/vi/abc/hqdefault.jpg",
+					},
+				},
+				"mediadescription": {
+					Attr: &pb.CloudEventAttributeValue_CeString{
+						CeString: "Video description",
+					},
+				},
+				"mediaduration": {
+					Attr: &pb.CloudEventAttributeValue_CeInteger{
+						CeInteger: 124,
+					},
+				},
+				"mediaviews": {
+					Attr: &pb.CloudEventAttributeValue_CeInteger{
+						CeInteger: math.MaxInt32,
+					},
+				},
+				"mediarating": {
+					Attr: &pb.CloudEventAttributeValue_CeString{
+						CeString: "4.85",
+					},
+				},
+				"mediavideoid": {
+					Attr: &pb.CloudEventAttributeValue_CeString{
+						CeString: "abc",
+					},
+				},
+			},
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			item := &rss.Item{
+				Link: "https://www.youtube.com/watch?v=abc",
+			}
+			feed := feeds.NewFeed(&rss.Feed{
+				UpdateURL: "https://www.youtube.com/feeds/videos.xml?channel_id=UC1",
+				Items: []*rss.Item{
+					item,
+				},
+			})
+			feed.SetExt(item, feeds.ItemExt{
+				Media: c.media,
+			})
+			msg := conv.Convert(feed, item)
+			for k := range c.attrs {
+				assert.Equal(t, c.attrs[k].String(), msg.Attributes[k].String(), k)
+			}
+			for _, k := range []string{"mediathumbnailurl", "mediadescription", "mediaduration", "mediaviews", "mediarating", "mediavideoid"} {
+				_, found := msg.Attributes[k]
+				_, expected := c.attrs[k]
+				assert.Equal(t, expected, found, k)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package converter
+
+import (
+	"github.com/SlyMarbo/rss"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	neturl "net/url"
+	"producer-rss/feeds"
+	"strings"
+	"time"
+)
+
+// convertEnclosure sets the attributes of the first audio or video enclosure of the item, if any.
+func (c converter) convertEnclosure(attrs map[string]*pb.CloudEventAttributeValue, feed *feeds.Feed, item *rss.Item, base *neturl.URL) {
+	for _, encl := range item.Enclosures {
+		if !strings.HasPrefix(encl.Type, "audio/") && !strings.HasPrefix(encl.Type, "video/") {
+			continue
+		}
+		u := c.resolveUrl(base, encl.URL, feed, item, "enclosure")
+		if u == nil {
+			continue
+		}
+		attrs[c.cfgMsg.Metadata.KeyEnclosureUrl] = &pb.CloudEventAttributeValue{
+			Attr: &pb.CloudEventAttributeValue_CeUri{
+				CeUri: u.String(),
+			},
+		}
+		attrs[c.cfgMsg.Metadata.KeyEnclosureType] = &pb.CloudEventAttributeValue{
+			Attr: &pb.CloudEventAttributeValue_CeString{
+				CeString: encl.Type,
+			},
+		}
+		if encl.Length > 0 {
+			attrs[c.cfgMsg.Metadata.KeyEnclosureLength] = &pb.CloudEventAttributeValue{
+				Attr: &pb.CloudEventAttributeValue_CeInteger{
+					CeInteger: clampInt32(uint64(encl.Length)),
+				},
+			}
+		}
+		break
+	}
+}
+
+// convertPodcast sets the iTunes and Podcasting 2.0 attributes of the item. The duration is in seconds.
+func (c converter) convertPodcast(attrs map[string]*pb.CloudEventAttributeValue, feed *feeds.Feed, item *rss.Item, base *neturl.URL) {
+	p := feed.Ext(item).Podcast
+	if p.Duration > 0 {
+		attrs[c.cfgMsg.Metadata.KeyPodcastDuration] = &pb.CloudEventAttributeValue{
+			Attr: &pb.CloudEventAttributeValue_CeInteger{
+				CeInteger: clampInt32(uint64(p.Duration.Round(time.Second) / time.Second)),
+			},
+		}
+	}
+	if p.Episode > 0 {
+		attrs[c.cfgMsg.Metadata.KeyPodcastEpisode] = &pb.CloudEventAttributeValue{
+			Attr: &pb.CloudEventAttributeValue_CeInteger{
+				CeInteger: clampInt32(uint64(p.Episode)),
+			},
+		}
+	}
+	if p.Season > 0 {
+		attrs[c.cfgMsg.Metadata.KeyPodcastSeason] = &pb.CloudEventAttributeValue{
+			Attr: &pb.CloudEventAttributeValue_CeInteger{
+				CeInteger: clampInt32(uint64(p.Season)),
+			},
+		}
+	}
+	if p.Explicit != nil {
+		attrs[c.cfgMsg.Metadata.KeyPodcastExplicit] = &pb.CloudEventAttributeValue{
+			Attr: &pb.CloudEventAttributeValue_CeBoolean{
+				CeBoolean: *p.Explicit,
+			},
+		}
+	}
+	if p.Author != "" {
+		attrs[c.cfgMsg.Metadata.KeyPodcastAuthor] = &pb.CloudEventAttributeValue{
+			Attr: &pb.CloudEventAttributeValue_CeString{
+				CeString: p.Author,
+			},
+		}
+	}
+	urls := map[string]string{
+		c.cfgMsg.Metadata.KeyPodcastImageUrl:      p.ImageUrl,
+		c.cfgMsg.Metadata.KeyPodcastTranscriptUrl: p.TranscriptUrl,
+		c.cfgMsg.Metadata.KeyPodcastChaptersUrl:   p.ChaptersUrl,
+	}
+	for k, s := range urls {
+		if u := c.resolveUrl(base, s, feed, item, k); u != nil {
+			attrs[k] = &pb.CloudEventAttributeValue{
+				Attr: &pb.CloudEventAttributeValue_CeUri{
+					CeUri: u.String(),
+				},
+			}
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package converter
+
+import (
+	"github.com/SlyMarbo/rss"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/slog"
+	"producer-rss/config"
+	"producer-rss/feeds"
+	"testing"
+	"time"
+)
+
+func TestConverter_Convert_Podcast(t *testing.T) {
+	cfgMsg := config.MessageConfig{
+		Metadata: config.MetadataConfig{
+			KeyImageUrl:             "imageurl",
+			KeyEnclosureLength:      "enclosurelength",
+			KeyEnclosureType:        "enclosuretype",
+			KeyEnclosureUrl:         "enclosureurl",
+			KeyPodcastAuthor:        "podcastauthor",
+			KeyPodcastChaptersUrl:   "podcastchaptersurl",
+			KeyPodcastDuration:      "podcastduration",
+			KeyPodcastEpisode:       "podcastepisode",
+			KeyPodcastExplicit:      "podcastexplicit",
+			KeyPodcastImageUrl:      "podcastimageurl",
+			KeyPodcastSeason:        "podcastseason",
+			KeyPodcastTranscriptUrl: "podcasttranscripturl",
+		},
+	}
+	conv := NewConverter(cfgMsg, slog.Default())
+	explicit := false
+	cases := map[string]struct {
+		encls   []*rss.Enclosure
+		podcast feeds.ItemPodcast
+		attrs   map[string]*pb.CloudEventAttributeValue
+	}{
+		"none": {
+			attrs: map[string]*pb.CloudEventAttributeValue{},
+		},
+		"episode": {
+			encls: []*rss.Enclosure{
+				{
+					URL:  "https://podcast.com/cover.jpg",
+					Type: "image/jpeg",
+				},
+				{
+					URL:    "/ep2.mp3",
+					Type:   "audio/mpeg",
+					Length: 12345,
+				},
+				{
+					URL:  "https://podcast.com/ep2.ogg",
+					Type: "audio/ogg",
+				},
+			},
+			podcast: feeds.ItemPodcast{
+				Duration:      time.Hour + 2*time.Minute + 3*time.Second,
+				Episode:       2,
+				Season:        1,
+				Explicit:      &explicit,
+				Author:        "John Doe",
+				ImageUrl:      "/ep2.jpg",
+				TranscriptUrl: "https://podcast.com/ep2.vtt",
+				ChaptersUrl:   "https://podcast.com/ep2.json",
+			},
+			attrs: map[string]*pb.CloudEventAttributeValue{
+				"imageurl": {
+					Attr: &pb.CloudEventAttributeValue_CeString{
+						CeString: "https://podcast.com/cover.jpg",
+					},
+				},
+				"enclosureurl": {
+					Attr: &pb.CloudEventAttributeValue_CeUri{
+						CeUri: "https://podcast.com/ep2.mp3",
+					},
+				},
+				"enclosuretype": {
+					Attr: &pb.CloudEventAttributeValue_CeString{
+						CeString: "audio/mpeg",
+					},
+				},
+				"enclosurelength": {
+					Attr: &pb.CloudEventAttributeValue_CeInteger{
+						CeInteger: 12345,
+					},
+				},
+				"podcastduration": {
+					Attr: &pb.CloudEventAttributeValue_CeInteger{
+						CeInteger: 3723,
+					},
+				},
+				"podcastepisode": {
+					Attr: &pb.CloudEventAttributeValue_CeInteger{
+						CeInteger: 2,
+					},
+				},
+				"podcastseason": {
+					Attr: &pb.CloudEventAttributeValue_CeInteger{
+						CeInteger: 1,
+					},
+				},
+				"podcastexplicit": {
+					Attr: &pb.CloudEventAttributeValue_CeBoolean{
+						CeBoolean: false,
+					},
+				},
+				"podcastauthor": {
+					Attr: &pb.CloudEventAttributeValue_CeString{
+						CeString: "John Doe",
+					},
+				},
+				"podcastimageurl": {
+					Attr: &pb.CloudEventAttributeValue_CeUri{
+						CeUri: "https://podcast.com/ep2.jpg",
+					},
+				},
+				"podcasttranscripturl": {
+					Attr: &pb.CloudEventAttributeValue_CeUri{
+						CeUri: "https://podcast.com/ep2.vtt",
+					},
+				},
+				"podcastchaptersurl": {
+					Attr: &pb.CloudEventAttributeValue_CeUri{
+						CeUri: "https://podcast.com/ep2.json",
+					},
+				},
+			},
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			item := &rss.Item{
+				Link:       "https://podcast.com/ep2",
+				Enclosures: c.encls,
+			}
+			feed := feeds.NewFeed(&rss.Feed{
+				UpdateURL: "https://podcast.com/rss",
+				Items: []*rss.Item{
+					item,
+				},
+			})
+			feed.SetExt(item, feeds.ItemExt{
+				Podcast: c.podcast,
+			})
+			msg := conv.Convert(feed, item)
+			for _, k := range []string{
+				"imageurl", "enclosurelength", "enclosuretype", "enclosureurl", "podcastauthor", "podcastchaptersurl",
+				"podcastduration", "podcastepisode", "podcastexplicit", "podcastimageurl", "podcastseason",
+				"podcasttranscripturl",
+			} {
+				assert.Equal(t, c.attrs[k].String(), msg.Attributes[k].String(), k)
+			}
+		})
+	}
+}
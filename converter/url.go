@@ -0,0 +1,75 @@
+package converter
+
+import (
+	"golang.org/x/exp/slices"
+	neturl "net/url"
+	"strings"
+)
+
+// resolveUrl returns the absolute URL resolved against the base one, if any. Returns false when the URL is invalid or
+// remains relative.
+func resolveUrl(base *neturl.URL, s string) (u *neturl.URL, ok bool) {
+	u, err := neturl.Parse(strings.TrimSpace(s))
+	if err == nil {
+		if base != nil && !u.IsAbs() {
+			u = base.ResolveReference(u)
+		}
+		ok = u.IsAbs() && u.Host != ""
+	}
+	return
+}
+
+// canonicalUrl returns the copy of the absolute URL having the lowercase scheme and host, no default port, not empty
+// path, no tracking query parameters except the kept ones and no fragment. The order and encoding of the remaining query
+// parameters are kept.
+func canonicalUrl(u *neturl.URL, trackingParams, keptParams []string) *neturl.URL {
+	cu := *u
+	cu.Scheme = strings.ToLower(cu.Scheme)
+	host := strings.ToLower(cu.Hostname())
+	if strings.Contains(host, ":") {
+		// IPv6
+		host = "[" + host + "]"
+	}
+	switch port := cu.Port(); {
+	case port == "", cu.Scheme == "http" && port == "80", cu.Scheme == "https" && port == "443":
+		cu.Host = host
+	default:
+		cu.Host = host + ":" + port
+	}
+	if cu.Path == "" && cu.Opaque == "" {
+		cu.Path = "/"
+	}
+	var params []string
+	for _, param := range strings.Split(cu.RawQuery, "&") {
+		name, _, _ := strings.Cut(param, "=")
+		if name, err := neturl.QueryUnescape(name); err == nil && trackingParam(name, trackingParams, keptParams) {
+			continue
+		}
+		if param != "" {
+			params = append(params, param)
+		}
+	}
+	cu.RawQuery = strings.Join(params, "&")
+	cu.ForceQuery = false
+	cu.Fragment = ""
+	cu.RawFragment = ""
+	return &cu
+}
+
+func trackingParam(name string, trackingParams, keptParams []string) (ok bool) {
+	name = strings.ToLower(name)
+	if slices.Contains(keptParams, name) {
+		return
+	}
+	for _, p := range trackingParams {
+		if prefix, wildcard := strings.CutSuffix(p, "*"); wildcard {
+			ok = strings.HasPrefix(name, prefix)
+		} else {
+			ok = name == p
+		}
+		if ok {
+			break
+		}
+	}
+	return
+}
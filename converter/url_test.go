@@ -0,0 +1,69 @@
+package converter
+
+import (
+	"github.com/stretchr/testify/assert"
+	neturl "net/url"
+	"testing"
+)
+
+func TestCanonicalUrl(t *testing.T) {
+	trackingParams := []string{
+		"utm_*",
+		"fbclid",
+		"rss",
+	}
+	cases := map[string]struct {
+		in   string
+		kept []string
+		out  string
+	}{
+		"already canonical": {
+			in:  "https://site.com/news/1?id=2",
+			out: "https://site.com/news/1?id=2",
+		},
+		"fragment": {
+			in:  "https://site.com/news/1?id=2#comments",
+			out: "https://site.com/news/1?id=2",
+		},
+		"host and scheme case": {
+			in:  "HTTPS://WWW.Site.Com/News/1",
+			out: "https://www.site.com/News/1",
+		},
+		"default port": {
+			in:  "http://site.com:80/1",
+			out: "http://site.com/1",
+		},
+		"custom port": {
+			in:  "https://site.com:8443/1",
+			out: "https://site.com:8443/1",
+		},
+		"empty path": {
+			in:  "https://site.com",
+			out: "https://site.com/",
+		},
+		"tracking params": {
+			in:  "https://site.com/1?utm_source=rss&id=2&UTM_Medium=feed&fbclid=abc&rss=1&q=a%20b",
+			out: "https://site.com/1?id=2&q=a%20b",
+		},
+		"kept params": {
+			in:   "https://site.com/1?utm_source=rss&Rss=1&id=2",
+			kept: []string{"rss"},
+			out:  "https://site.com/1?Rss=1&id=2",
+		},
+		"only tracking params": {
+			in:  "https://site.com/1?utm_source=rss&rss=1",
+			out: "https://site.com/1",
+		},
+		"ipv6": {
+			in:  "http://[::1]:80/1",
+			out: "http://[::1]/1",
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			u, err := neturl.Parse(c.in)
+			assert.Nil(t, err)
+			assert.Equal(t, c.out, canonicalUrl(u, trackingParams, c.kept).String())
+		})
+	}
+}
@@ -1,11 +1,21 @@
 package feeds
 
-import "net/http"
+import (
+	"context"
+	"io"
+	"net/http"
+)
 
 type Client interface {
-	Get(url string) (resp *http.Response, err error)
+	// Get requests the feed document by the specified URL.
+	// The request is conditional when the validators are not empty.
+	Get(ctx context.Context, url string, v Validators) (resp *http.Response, err error)
 }
 
+// acceptFeed prefers the feed media types, the generic XML and JSON are accepted as well.
+const acceptFeed = "application/rss+xml, application/atom+xml, application/feed+json, application/xml;q=0.9, " +
+	"text/xml;q=0.9, application/json;q=0.8, */*;q=0.5"
+
 type client struct {
 	httpClient http.Client
 	userAgent  string
@@ -18,11 +28,25 @@ func NewClient(httpClient http.Client, userAgent string) Client {
 	}
 }
 
-func (c client) Get(url string) (resp *http.Response, err error) {
-	req, err := http.NewRequest("GET", url, nil)
+func (c client) Get(ctx context.Context, url string, v Validators) (resp *http.Response, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("User-Agent", c.userAgent)
+	req.Header.Set("Accept", acceptFeed)
+	v.apply(req)
 	return c.httpClient.Do(req)
 }
+
+// closeHook invokes the hook function after the response body is closed.
+type closeHook struct {
+	io.ReadCloser
+	hook func()
+}
+
+func (ch closeHook) Close() (err error) {
+	err = ch.ReadCloser.Close()
+	ch.hook()
+	return
+}
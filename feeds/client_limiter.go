@@ -0,0 +1,110 @@
+package feeds
+
+import (
+	"context"
+	"net/http"
+	neturl "net/url"
+	"producer-rss/backoff"
+	"producer-rss/config"
+	"strings"
+	"sync"
+	"time"
+)
+
+type limiterMiddleware struct {
+	client Client
+	cfg    config.FeedHostLimitConfig
+	lock   *sync.Mutex
+	hosts  map[string]*hostLimiter
+}
+
+type hostLimiter struct {
+	// sem limits the number of the concurrent requests to the host, nil if unlimited
+	sem      chan struct{}
+	delayMin time.Duration
+	lock     sync.Mutex
+	// next is the earliest time to start the next request to the host
+	next time.Time
+}
+
+// NewLimiterMiddleware limits the requests rate per host: the requests to the same host are started not more often
+// than the min delay and not more than the max number of these may be in progress at the same time.
+// A request is in progress until its response body is closed. The limits may be overridden for the specific hosts.
+// The middleware should be shared by all the feeds to be effective.
+func NewLimiterMiddleware(client Client, cfg config.FeedHostLimitConfig) Client {
+	return limiterMiddleware{
+		client: client,
+		cfg:    cfg,
+		lock:   &sync.Mutex{},
+		hosts:  map[string]*hostLimiter{},
+	}
+}
+
+func (lm limiterMiddleware) Get(ctx context.Context, url string, v Validators) (resp *http.Response, err error) {
+	var host string
+	if u, errParse := neturl.Parse(url); errParse == nil {
+		host = strings.ToLower(u.Hostname())
+	}
+	hl := lm.hostLimiter(host)
+	if hl.sem != nil {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		case hl.sem <- struct{}{}:
+		}
+	}
+	err = backoff.Sleep(ctx, hl.reserve(time.Now()))
+	if err == nil {
+		resp, err = lm.client.Get(ctx, url, v)
+	}
+	switch {
+	case hl.sem == nil:
+	case err != nil:
+		<-hl.sem
+	default:
+		resp.Body = closeHook{
+			ReadCloser: resp.Body,
+			hook: func() {
+				<-hl.sem
+			},
+		}
+	}
+	return
+}
+
+func (lm limiterMiddleware) hostLimiter(host string) (hl *hostLimiter) {
+	lm.lock.Lock()
+	defer lm.lock.Unlock()
+	hl = lm.hosts[host]
+	if hl == nil {
+		concurrencyMax, ok := lm.cfg.ConcurrencyMaxByHost[host]
+		if !ok {
+			concurrencyMax = lm.cfg.ConcurrencyMax
+		}
+		delayMin, ok := lm.cfg.DelayMinByHost[host]
+		if !ok {
+			delayMin = lm.cfg.DelayMin
+		}
+		hl = &hostLimiter{
+			delayMin: delayMin,
+		}
+		if concurrencyMax > 0 {
+			hl.sem = make(chan struct{}, concurrencyMax)
+		}
+		lm.hosts[host] = hl
+	}
+	return
+}
+
+// reserve returns the time to wait before the request may be started.
+func (hl *hostLimiter) reserve(now time.Time) (wait time.Duration) {
+	hl.lock.Lock()
+	defer hl.lock.Unlock()
+	start := now
+	if hl.next.After(now) {
+		start = hl.next
+	}
+	hl.next = start.Add(hl.delayMin)
+	return start.Sub(now)
+}
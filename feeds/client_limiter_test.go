@@ -0,0 +1,83 @@
+package feeds
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"producer-rss/config"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimiterMiddleware_Get(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	urlIp := srv.URL
+	urlLocalhost := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+	cfg := config.FeedHostLimitConfig{
+		ConcurrencyMax: 1,
+		DelayMin:       100 * time.Millisecond,
+		DelayMinByHost: map[string]time.Duration{
+			"localhost": 0,
+		},
+	}
+	client := NewLimiterMiddleware(NewClient(http.Client{}, "test"), cfg)
+	get := func(ctx context.Context, url string) (resp *http.Response, err error) {
+		resp, err = client.Get(ctx, url, Validators{})
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		return
+	}
+	//
+	t.Run("min delay", func(t *testing.T) {
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			_, err := get(context.TODO(), urlIp)
+			require.Nil(t, err)
+		}
+		assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+	})
+	//
+	t.Run("min delay overridden", func(t *testing.T) {
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			_, err := get(context.TODO(), urlLocalhost)
+			require.Nil(t, err)
+		}
+		assert.Less(t, time.Since(start), 100*time.Millisecond)
+	})
+	//
+	t.Run("max concurrency", func(t *testing.T) {
+		resp, err := client.Get(context.TODO(), urlLocalhost, Validators{})
+		require.Nil(t, err)
+		// the 1st response body is not closed yet
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		_, err = get(ctx, urlLocalhost)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		// another host is not affected
+		_, err = get(context.TODO(), urlIp)
+		assert.Nil(t, err)
+		//
+		require.Nil(t, resp.Body.Close())
+		_, err = get(context.TODO(), urlLocalhost)
+		assert.Nil(t, err)
+	})
+}
+
+func TestHostLimiter_reserve(t *testing.T) {
+	hl := &hostLimiter{
+		delayMin: time.Minute,
+	}
+	now := time.Date(2023, 6, 9, 7, 31, 50, 0, time.UTC)
+	assert.Equal(t, time.Duration(0), hl.reserve(now))
+	assert.Equal(t, time.Minute, hl.reserve(now))
+	assert.Equal(t, 90*time.Second, hl.reserve(now.Add(30*time.Second)))
+	assert.Equal(t, time.Duration(0), hl.reserve(now.Add(time.Hour)))
+}
@@ -1,6 +1,7 @@
 package feeds
 
 import (
+	"context"
 	"fmt"
 	"golang.org/x/exp/slog"
 	"net/http"
@@ -18,13 +19,13 @@ func NewLoggingMiddleware(client Client, log *slog.Logger) Client {
 	}
 }
 
-func (lm loggingMiddleware) Get(url string) (resp *http.Response, err error) {
+func (lm loggingMiddleware) Get(ctx context.Context, url string, v Validators) (resp *http.Response, err error) {
 	defer func() {
 		var statusCode int
 		if resp != nil {
 			statusCode = resp.StatusCode
 		}
-		lm.log.Debug(fmt.Sprintf("client.Get(url=%s): %d, %s", url, statusCode, err))
+		lm.log.Debug(fmt.Sprintf("client.Get(url=%s, %+v): %d, %s", url, v, statusCode, err))
 	}()
-	return lm.client.Get(url)
+	return lm.client.Get(ctx, url, v)
 }
@@ -1,8 +1,11 @@
 package feeds
 
 import (
+	"context"
+	"errors"
 	"io"
 	"net/http"
+	neturl "net/url"
 	"strings"
 )
 
@@ -166,9 +169,53 @@ func NewClientMock() Client {
 	return clientMock{}
 }
 
-func (cm clientMock) Get(url string) (resp *http.Response, err error) {
-	resp = &http.Response{}
-	resp.StatusCode = http.StatusOK
-	resp.Body = io.NopCloser(strings.NewReader(rssContentMock))
+var errClientMock = errors.New("fetch failure")
+
+const etagMock = `"rss-content-mock"`
+
+func (cm clientMock) Get(ctx context.Context, url string, v Validators) (resp *http.Response, err error) {
+	switch {
+	case url == "fetch-fail":
+		err = errClientMock
+	case url == "missing":
+		resp = &http.Response{}
+		resp.StatusCode = http.StatusNotFound
+		resp.Body = io.NopCloser(strings.NewReader("not found"))
+	case url == "gone":
+		resp = &http.Response{}
+		resp.StatusCode = http.StatusGone
+		resp.Body = io.NopCloser(strings.NewReader("gone"))
+	case url == "unavailable":
+		resp = &http.Response{}
+		resp.StatusCode = http.StatusServiceUnavailable
+		resp.Body = io.NopCloser(strings.NewReader("unavailable"))
+	case url == "parse-fail":
+		resp = &http.Response{}
+		resp.StatusCode = http.StatusOK
+		resp.Body = io.NopCloser(strings.NewReader("<html><body>not a feed</body></html>"))
+	case url == "https://moved.com/rss":
+		resp = &http.Response{}
+		resp.StatusCode = http.StatusOK
+		resp.Body = io.NopCloser(strings.NewReader(rssContentMock))
+		resp.Request = &http.Request{
+			URL: &neturl.URL{Scheme: "https", Host: "new.com", Path: "/rss"},
+			Response: &http.Response{
+				StatusCode: http.StatusMovedPermanently,
+				Request: &http.Request{
+					URL: &neturl.URL{Scheme: "https", Host: "moved.com", Path: "/rss"},
+				},
+			},
+		}
+	case v.ETag == etagMock:
+		resp = &http.Response{}
+		resp.StatusCode = http.StatusNotModified
+		resp.Body = io.NopCloser(strings.NewReader(""))
+	default:
+		resp = &http.Response{}
+		resp.StatusCode = http.StatusOK
+		resp.Header = http.Header{}
+		resp.Header.Set("ETag", etagMock)
+		resp.Body = io.NopCloser(strings.NewReader(rssContentMock))
+	}
 	return
 }
@@ -0,0 +1,128 @@
+package feeds
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"producer-rss/backoff"
+	"producer-rss/config"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+type retryMiddleware struct {
+	client  Client
+	cfg     config.FeedRetryConfig
+	backoff backoff.Exponential
+}
+
+// NewRetryMiddleware retries the failed requests with the exponential backoff.
+// Only the transient network failures, timeouts, 408, 429 and 5xx responses are retried. The delay before the retry is
+// increased to the value of the Retry-After response header, if any. Each attempt is limited by the attempt timeout,
+// all attempts together are limited by the overall timeout. When no attempts left, the last response or error is
+// returned.
+func NewRetryMiddleware(client Client, cfg config.FeedRetryConfig) Client {
+	return retryMiddleware{
+		client: client,
+		cfg:    cfg,
+		backoff: backoff.Exponential{
+			Initial: cfg.Backoff,
+			Max:     cfg.BackoffMax,
+		},
+	}
+}
+
+func (rm retryMiddleware) Get(ctx context.Context, url string, v Validators) (resp *http.Response, err error) {
+	ctx, cancel := context.WithTimeout(ctx, rm.cfg.Timeout)
+	var attemptCtx context.Context
+	var attemptCancel context.CancelFunc
+	for attempt := uint32(1); ; attempt++ {
+		attemptCtx, attemptCancel = context.WithTimeout(ctx, rm.cfg.AttemptTimeout)
+		resp, err = rm.client.Get(attemptCtx, url, v)
+		if attempt >= rm.cfg.AttemptsMax || ctx.Err() != nil || !retryable(resp, err) {
+			break
+		}
+		delay := rm.backoff.Delay(attempt - 1)
+		if resp != nil {
+			if retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); retryAfter > delay {
+				delay = retryAfter
+			}
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			// no time left for the next attempt, return the current result
+			break
+		}
+		if resp != nil {
+			_ = resp.Body.Close()
+		}
+		attemptCancel()
+		err = backoff.Sleep(ctx, delay)
+		if err != nil {
+			resp = nil
+			cancel()
+			return
+		}
+	}
+	if resp == nil {
+		attemptCancel()
+		cancel()
+	} else {
+		// the contexts should remain valid until the response body is read
+		resp.Body = closeHook{
+			ReadCloser: resp.Body,
+			hook: func() {
+				attemptCancel()
+				cancel()
+			},
+		}
+	}
+	return
+}
+
+func retryable(resp *http.Response, err error) (ok bool) {
+	switch {
+	case err != nil:
+		ok = retryableErr(err)
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests:
+		ok = true
+	case resp.StatusCode == http.StatusNotImplemented, resp.StatusCode == http.StatusHTTPVersionNotSupported:
+		ok = false
+	case resp.StatusCode >= 500:
+		ok = true
+	}
+	return
+}
+
+// retryableErr returns true for the transient transport failures only: timeouts, temporary network errors, connection
+// resets and unexpected EOFs. The permanent ones like the malformed URL, unsupported scheme, unknown host or TLS
+// certificate failures are not retried.
+func retryableErr(err error) (ok bool) {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, syscall.ECONNRESET):
+		ok = true
+	case errors.As(err, &netErr):
+		ok = netErr.Timeout() || netErr.Temporary()
+	}
+	return
+}
+
+// parseRetryAfter returns the delay specified by the Retry-After header value in either delay-seconds or HTTP date
+// format, or zero if the value is missing or invalid.
+func parseRetryAfter(val string, now time.Time) (d time.Duration) {
+	if val == "" {
+		return
+	}
+	if secs, err := strconv.ParseUint(val, 10, 32); err == nil {
+		d = time.Duration(secs) * time.Second
+	} else if t, err := http.ParseTime(val); err == nil {
+		d = t.Sub(now)
+	}
+	if d < 0 {
+		d = 0
+	}
+	return
+}
@@ -0,0 +1,188 @@
+package feeds
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	neturl "net/url"
+	"os"
+	"producer-rss/config"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRetryMiddleware_Get(t *testing.T) {
+	cases := map[string]struct {
+		statuses   []int
+		retryAfter string
+		cfg        config.FeedRetryConfig
+		status     int
+		attempts   uint32
+		err        error
+	}{
+		"ok": {
+			statuses: []int{200},
+			status:   200,
+			attempts: 1,
+		},
+		"recovered": {
+			statuses: []int{503, 502, 200},
+			status:   200,
+			attempts: 3,
+		},
+		"attempts exhausted": {
+			statuses: []int{500, 500, 500, 500},
+			status:   500,
+			attempts: 3,
+		},
+		"not found is not retried": {
+			statuses: []int{404, 200},
+			status:   404,
+			attempts: 1,
+		},
+		"gone is not retried": {
+			statuses: []int{410, 200},
+			status:   410,
+			attempts: 1,
+		},
+		"too many requests": {
+			statuses: []int{429, 200},
+			status:   200,
+			attempts: 2,
+		},
+		"retry after exceeds the timeout": {
+			statuses:   []int{429, 200},
+			retryAfter: "3600",
+			status:     429,
+			attempts:   1,
+		},
+		"attempt timeout": {
+			statuses: []int{-1, 200},
+			status:   200,
+			attempts: 2,
+		},
+		"timeout": {
+			statuses: []int{-1, -1, -1},
+			cfg: config.FeedRetryConfig{
+				AttemptsMax:    3,
+				AttemptTimeout: 100 * time.Millisecond,
+				Backoff:        time.Millisecond,
+				BackoffMax:     time.Millisecond,
+				Timeout:        150 * time.Millisecond,
+			},
+			attempts: 2,
+			err:      context.DeadlineExceeded,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			var attempts uint32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				i := atomic.AddUint32(&attempts, 1) - 1
+				status := c.statuses[i]
+				if status < 0 {
+					// hang until the client gives up
+					<-r.Context().Done()
+					return
+				}
+				if c.retryAfter != "" {
+					w.Header().Set("Retry-After", c.retryAfter)
+				}
+				w.WriteHeader(status)
+				_, _ = w.Write([]byte("body"))
+			}))
+			defer srv.Close()
+			cfg := c.cfg
+			if cfg.AttemptsMax == 0 {
+				cfg = config.FeedRetryConfig{
+					AttemptsMax:    3,
+					AttemptTimeout: 100 * time.Millisecond,
+					Backoff:        time.Millisecond,
+					BackoffMax:     10 * time.Millisecond,
+					Timeout:        time.Second,
+				}
+			}
+			client := NewRetryMiddleware(NewClient(http.Client{}, "test"), cfg)
+			resp, err := client.Get(context.TODO(), srv.URL, Validators{})
+			if c.err == nil {
+				require.Nil(t, err)
+				assert.Equal(t, c.status, resp.StatusCode)
+				// the body should be readable after the middleware returned
+				data, err := io.ReadAll(resp.Body)
+				assert.Nil(t, err)
+				assert.Equal(t, "body", string(data))
+				assert.Nil(t, resp.Body.Close())
+			} else {
+				assert.ErrorIs(t, err, c.err)
+			}
+			assert.Equal(t, c.attempts, atomic.LoadUint32(&attempts))
+		})
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	cases := map[string]struct {
+		err error
+		ok  bool
+	}{
+		"timeout": {
+			err: &neturl.Error{Op: "Get", URL: "https://host", Err: context.DeadlineExceeded},
+			ok:  true,
+		},
+		"connection reset": {
+			err: &neturl.Error{Op: "Get", URL: "https://host", Err: &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}},
+			ok:  true,
+		},
+		"unexpected eof": {
+			err: &neturl.Error{Op: "Get", URL: "https://host", Err: io.ErrUnexpectedEOF},
+			ok:  true,
+		},
+		"temporary dns failure": {
+			err: &neturl.Error{Op: "Get", URL: "https://host", Err: &net.DNSError{Err: "server misbehaving", Name: "host", IsTemporary: true}},
+			ok:  true,
+		},
+		"unknown host": {
+			err: &neturl.Error{Op: "Get", URL: "https://host", Err: &net.DNSError{Err: "no such host", Name: "host", IsNotFound: true}},
+		},
+		"unsupported scheme": {
+			err: &neturl.Error{Op: "Get", URL: "ftp://host", Err: errors.New("unsupported protocol scheme \"ftp\"")},
+		},
+		"malformed url": {
+			err: &neturl.Error{Op: "parse", URL: "https://host:port", Err: errors.New("invalid port")},
+		},
+		"certificate": {
+			err: &neturl.Error{Op: "Get", URL: "https://host", Err: &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}},
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			assert.Equal(t, c.ok, retryable(nil, c.err))
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2023, 6, 9, 7, 31, 50, 0, time.UTC)
+	cases := map[string]time.Duration{
+		"":                              0,
+		"120":                           2 * time.Minute,
+		"-1":                            0,
+		"soon":                          0,
+		"Fri, 09 Jun 2023 07:32:50 GMT": time.Minute,
+		"Fri, 09 Jun 2023 07:30:50 GMT": 0,
+	}
+	for val, d := range cases {
+		t.Run(val, func(t *testing.T) {
+			assert.Equal(t, d, parseRetryAfter(val, now))
+		})
+	}
+}
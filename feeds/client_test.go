@@ -0,0 +1,46 @@
+package feeds
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClient_Get(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/slow":
+			select {
+			case <-r.Context().Done():
+			case <-time.After(10 * time.Second):
+			}
+		case r.Header.Get("If-None-Match") == `"v1"`:
+			w.WriteHeader(http.StatusNotModified)
+		default:
+			w.Header().Set("ETag", `"v1"`)
+			_, _ = w.Write([]byte(r.Header.Get("User-Agent")))
+		}
+	}))
+	defer srv.Close()
+	c := NewClient(http.Client{}, "test-agent")
+	//
+	resp, err := c.Get(context.TODO(), srv.URL, Validators{})
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, Validators{ETag: `"v1"`}, NewValidators(resp.Header))
+	_ = resp.Body.Close()
+	//
+	resp, err = c.Get(context.TODO(), srv.URL, Validators{ETag: `"v1"`})
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+	_ = resp.Body.Close()
+	//
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = c.Get(ctx, srv.URL+"/slow", Validators{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,88 @@
+package feeds
+
+import (
+	"github.com/SlyMarbo/rss"
+	"time"
+)
+
+const contentTypeHtml = "text/html"
+const contentTypeText = "text/plain"
+
+// Feed is the parsed feed document with the item data not supported by the rss package.
+type Feed struct {
+	*rss.Feed
+	// ListUrl is the feed URL as listed, differs from the UpdateURL when the feed has moved. Empty if not known.
+	ListUrl string
+	// Authors are the feed author names when the feed document declares several ones, e.g. the JSON Feed authors.
+	Authors []string
+	exts    map[*rss.Item]ItemExt
+}
+
+// ItemExt is the feed item data not supported by the rss package.
+type ItemExt struct {
+	// OrigLink is the original item link of the feed proxied by FeedBurner (feedburner:origLink).
+	OrigLink string
+	// ContentType is the item content type when it's declared by the feed document, e.g. the JSON Feed content_text.
+	ContentType string
+	// SummaryType is the item summary type when it's declared by the feed document, e.g. the Atom summary type.
+	SummaryType string
+	// ContentBase and SummaryBase are the xml:base URLs in effect for the item content and summary, empty when none is
+	// declared.
+	ContentBase string
+	SummaryBase string
+	// Authors are the item author names.
+	Authors []string
+	Media   ItemMedia
+	Podcast ItemPodcast
+}
+
+// ItemMedia is the Media RSS (including YouTube) data of the feed item.
+type ItemMedia struct {
+	// ThumbnailUrl is the URL of the widest media:thumbnail.
+	ThumbnailUrl string
+	Description  string
+	// Duration is the media:content duration, zero when unknown.
+	Duration time.Duration
+	// Views is the media:statistics views count.
+	Views uint64
+	// Rating is the media:starRating average as is, e.g. "4.85".
+	Rating string
+	// VideoId is the YouTube video id (yt:videoId).
+	VideoId string
+}
+
+// ItemPodcast is the iTunes and Podcasting 2.0 data of the feed item.
+type ItemPodcast struct {
+	// Duration is the itunes:duration, zero when unknown.
+	Duration time.Duration
+	// Episode and Season are the itunes:episode and itunes:season numbers, zero when unknown.
+	Episode int
+	Season  int
+	// Explicit is the itunes:explicit flag, nil when unknown.
+	Explicit *bool
+	Author   string
+	// ImageUrl is the itunes:image href.
+	ImageUrl string
+	// TranscriptUrl is the first podcast:transcript URL.
+	TranscriptUrl string
+	// ChaptersUrl is the podcast:chapters URL.
+	ChaptersUrl string
+}
+
+// NewFeed returns the feed having no item extensions.
+func NewFeed(feed *rss.Feed) *Feed {
+	return &Feed{
+		Feed: feed,
+		exts: map[*rss.Item]ItemExt{},
+	}
+}
+
+// Ext returns the extensions of the feed item, empty if none.
+func (f *Feed) Ext(item *rss.Item) ItemExt {
+	return f.exts[item]
+}
+
+// SetExt sets the extensions of the feed item.
+func (f *Feed) SetExt(item *rss.Item, ext ItemExt) {
+	f.exts[item] = ext
+}
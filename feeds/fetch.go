@@ -0,0 +1,90 @@
+package feeds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ErrUnexpectedStatus means the feed server responded with the status code other than 2xx or 304.
+var ErrUnexpectedStatus = errors.New("unexpected response status")
+
+// ErrNotFound means the feed server responded with 404 Not Found.
+var ErrNotFound = errors.New("not found")
+
+// ErrGone means the feed server responded with 410 Gone.
+var ErrGone = errors.New("gone")
+
+// ErrParse means the fetched feed document is not a valid feed.
+var ErrParse = errors.New("failed to parse the feed")
+
+// FetchResult is the fetched feed document.
+type FetchResult struct {
+	Feed       *Feed
+	Validators Validators
+	// Location is the final feed document URL when it was reached by the permanent redirects only, otherwise empty.
+	Location string
+}
+
+// Fetch requests the feed document using the specified client and parses it.
+// Returns ErrNotModified when the validators are matching the current feed document.
+// Otherwise, returns the parsed feed and the new validators.
+func Fetch(ctx context.Context, client Client, url string, v Validators) (fr FetchResult, err error) {
+	var resp *http.Response
+	resp, err = client.Get(ctx, url, v)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	fr.Location = permanentLocation(resp)
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		err = ErrNotModified
+		return
+	case resp.StatusCode == http.StatusNotFound:
+		err = ErrNotFound
+		return
+	case resp.StatusCode == http.StatusGone:
+		err = ErrGone
+		return
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		err = fmt.Errorf("%w: %d", ErrUnexpectedStatus, resp.StatusCode)
+		return
+	}
+	var data []byte
+	data, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	fr.Feed, err = parse(data, resp.Header.Get("Content-Type"), url)
+	if err != nil {
+		err = fmt.Errorf("%w: %s", ErrParse, err)
+		return
+	}
+	if fr.Feed.Link == "" {
+		fr.Feed.Link = url
+	}
+	fr.Feed.UpdateURL = url
+	fr.Validators = NewValidators(resp.Header)
+	return
+}
+
+// permanentLocation returns the final request URL if every redirect followed was permanent (301 or 308).
+// Returns empty string if there were no redirects or any of these was temporary.
+func permanentLocation(resp *http.Response) (location string) {
+	req := resp.Request
+	if req == nil || req.Response == nil {
+		return
+	}
+	for r := req; r != nil && r.Response != nil; r = r.Response.Request {
+		switch r.Response.StatusCode {
+		case http.StatusMovedPermanently, http.StatusPermanentRedirect:
+		default:
+			return
+		}
+	}
+	location = req.URL.String()
+	return
+}
@@ -0,0 +1,101 @@
+package feeds
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetch(t *testing.T) {
+	cases := map[string]struct {
+		url   string
+		v     Validators
+		count int
+		newV  Validators
+		err   error
+	}{
+		"ok": {
+			url:   "https://feed.com/rss",
+			count: 9,
+			newV: Validators{
+				ETag: etagMock,
+			},
+		},
+		"not modified": {
+			url: "https://feed.com/rss",
+			v: Validators{
+				ETag: etagMock,
+			},
+			err: ErrNotModified,
+		},
+		"fetch failure": {
+			url: "fetch-fail",
+			err: errClientMock,
+		},
+		"not found": {
+			url: "missing",
+			err: ErrNotFound,
+		},
+		"gone": {
+			url: "gone",
+			err: ErrGone,
+		},
+		"unexpected status": {
+			url: "unavailable",
+			err: ErrUnexpectedStatus,
+		},
+		"parse failure": {
+			url: "parse-fail",
+			err: ErrParse,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			fr, err := Fetch(context.TODO(), NewClientMock(), c.url, c.v)
+			switch {
+			case c.err != nil:
+				assert.ErrorIs(t, err, c.err)
+				assert.Nil(t, fr.Feed)
+			default:
+				assert.Nil(t, err)
+				assert.Equal(t, c.count, len(fr.Feed.Items))
+				assert.Equal(t, c.url, fr.Feed.UpdateURL)
+				assert.Equal(t, c.newV, fr.Validators)
+			}
+		})
+	}
+}
+
+func TestFetch_Location(t *testing.T) {
+	var srvUrl string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, srvUrl+"/older", http.StatusMovedPermanently)
+		case "/older":
+			http.Redirect(w, r, srvUrl+"/new", http.StatusPermanentRedirect)
+		case "/temp":
+			http.Redirect(w, r, srvUrl+"/old", http.StatusFound)
+		case "/new":
+			_, _ = w.Write([]byte(rssContentMock))
+		}
+	}))
+	defer srv.Close()
+	srvUrl = srv.URL
+	client := NewClient(http.Client{}, "test")
+	cases := map[string]string{
+		"/new":  "",
+		"/old":  srvUrl + "/new",
+		"/temp": "",
+	}
+	for path, location := range cases {
+		t.Run(path, func(t *testing.T) {
+			fr, err := Fetch(context.TODO(), client, srvUrl+path, Validators{})
+			assert.Nil(t, err)
+			assert.Equal(t, location, fr.Location)
+			assert.Equal(t, 9, len(fr.Feed.Items))
+		})
+	}
+}
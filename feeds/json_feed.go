@@ -0,0 +1,194 @@
+package feeds
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/SlyMarbo/rss"
+	"strings"
+	"time"
+)
+
+const jsonFeedVersionPrefix = "https://jsonfeed.org/version/"
+
+// ErrJsonFeedVersion means the JSON document is not a JSON Feed.
+var ErrJsonFeedVersion = errors.New("unsupported JSON Feed version")
+
+// jsonFeed is the JSON Feed 1.0 or 1.1 document, see https://www.jsonfeed.org/version/1.1/
+type jsonFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageUrl string           `json:"home_page_url"`
+	Description string           `json:"description"`
+	Icon        string           `json:"icon"`
+	Favicon     string           `json:"favicon"`
+	Language    string           `json:"language"`
+	Author      *jsonFeedAuthor  `json:"author"`
+	Authors     []jsonFeedAuthor `json:"authors"`
+	Items       []jsonFeedItem   `json:"items"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+	Url  string `json:"url"`
+}
+
+type jsonFeedItem struct {
+	// Id is a string by the spec, but some feeds use the numbers
+	Id            json.RawMessage      `json:"id"`
+	Url           string               `json:"url"`
+	ExternalUrl   string               `json:"external_url"`
+	Title         string               `json:"title"`
+	ContentHtml   string               `json:"content_html"`
+	ContentText   string               `json:"content_text"`
+	Summary       string               `json:"summary"`
+	Image         string               `json:"image"`
+	BannerImage   string               `json:"banner_image"`
+	DatePublished string               `json:"date_published"`
+	DateModified  string               `json:"date_modified"`
+	Author        *jsonFeedAuthor      `json:"author"`
+	Authors       []jsonFeedAuthor     `json:"authors"`
+	Tags          []string             `json:"tags"`
+	Attachments   []jsonFeedAttachment `json:"attachments"`
+}
+
+type jsonFeedAttachment struct {
+	Url         string `json:"url"`
+	MimeType    string `json:"mime_type"`
+	SizeInBytes uint   `json:"size_in_bytes"`
+}
+
+// parseJsonFeed maps the JSON Feed document to the same model as the RSS and Atom ones. The items having neither id
+// nor URL and the items with the duplicate ids are ignored like the rss package does.
+func parseJsonFeed(data []byte) (feed *Feed, err error) {
+	var jf jsonFeed
+	err = json.Unmarshal(bytes.TrimPrefix(data, bom), &jf)
+	if err == nil && !strings.HasPrefix(jf.Version, jsonFeedVersionPrefix) {
+		err = fmt.Errorf("%w: %q", ErrJsonFeedVersion, jf.Version)
+	}
+	if err != nil {
+		return
+	}
+	authors := jsonFeedAuthorNames(jf.Author, jf.Authors)
+	rssFeed := &rss.Feed{
+		Title:       jf.Title,
+		Language:    jf.Language,
+		Author:      strings.Join(authors, ", "),
+		Description: jf.Description,
+		Link:        jf.HomePageUrl,
+		ItemMap:     map[string]struct{}{},
+		Refresh:     time.Now().Add(rss.DefaultRefreshInterval),
+	}
+	switch {
+	case jf.Icon != "":
+		rssFeed.Image = &rss.Image{
+			URL: jf.Icon,
+		}
+	case jf.Favicon != "":
+		rssFeed.Image = &rss.Image{
+			URL: jf.Favicon,
+		}
+	}
+	feed = NewFeed(rssFeed)
+	feed.Authors = authors
+	for _, ji := range jf.Items {
+		item, ext := ji.convert()
+		if item.ID == "" {
+			continue
+		}
+		if _, found := rssFeed.ItemMap[item.ID]; found {
+			continue
+		}
+		rssFeed.Items = append(rssFeed.Items, item)
+		rssFeed.ItemMap[item.ID] = struct{}{}
+		rssFeed.Unread++
+		feed.SetExt(item, ext)
+	}
+	return
+}
+
+func (ji jsonFeedItem) convert() (item *rss.Item, ext ItemExt) {
+	item = &rss.Item{
+		Title:      ji.Title,
+		Summary:    ji.Summary,
+		Categories: ji.Tags,
+		Link:       ji.Url,
+		ID:         jsonFeedItemId(ji.Id),
+	}
+	if item.Link == "" {
+		item.Link = ji.ExternalUrl
+	}
+	if item.ID == "" {
+		item.ID = item.Link
+	}
+	switch {
+	case ji.ContentHtml != "":
+		item.Content = ji.ContentHtml
+		ext.ContentType = contentTypeHtml
+	case ji.ContentText != "":
+		item.Content = ji.ContentText
+		ext.ContentType = contentTypeText
+	}
+	switch {
+	case ji.Image != "":
+		item.Image = &rss.Image{
+			URL: ji.Image,
+		}
+	case ji.BannerImage != "":
+		item.Image = &rss.Image{
+			URL: ji.BannerImage,
+		}
+	}
+	for _, d := range []string{ji.DatePublished, ji.DateModified} {
+		if d != "" {
+			if t, err := time.Parse(time.RFC3339, d); err == nil {
+				item.Date = t
+				item.DateValid = true
+				break
+			}
+		}
+	}
+	for _, a := range ji.Attachments {
+		if a.Url != "" {
+			item.Enclosures = append(item.Enclosures, &rss.Enclosure{
+				URL:    a.Url,
+				Type:   a.MimeType,
+				Length: a.SizeInBytes,
+			})
+		}
+	}
+	if item.Summary != "" {
+		// the JSON Feed summary is the plain text
+		ext.SummaryType = contentTypeText
+	}
+	ext.Authors = jsonFeedAuthorNames(ji.Author, ji.Authors)
+	return
+}
+
+// jsonFeedItemId returns the item id either string or number, empty if none.
+func jsonFeedItemId(raw json.RawMessage) (id string) {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		id = s
+	} else {
+		var n json.Number
+		if json.Unmarshal(raw, &n) == nil {
+			id = n.String()
+		}
+	}
+	return
+}
+
+// jsonFeedAuthorNames returns the names of the JSON Feed 1.1 authors or the JSON Feed 1.0 author.
+func jsonFeedAuthorNames(author *jsonFeedAuthor, authors []jsonFeedAuthor) (names []string) {
+	if len(authors) == 0 && author != nil {
+		authors = []jsonFeedAuthor{*author}
+	}
+	for _, a := range authors {
+		if a.Name != "" {
+			names = append(names, a.Name)
+		}
+	}
+	return
+}
@@ -0,0 +1,132 @@
+package feeds
+
+import (
+	"github.com/SlyMarbo/rss"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+const jsonFeedMock = `{
+  "version": "https://jsonfeed.org/version/1.1",
+  "title": "Blog",
+  "home_page_url": "https://blog.com/",
+  "feed_url": "https://blog.com/feed.json",
+  "description": "The blog",
+  "icon": "https://blog.com/icon.png",
+  "language": "en",
+  "authors": [
+    {"name": "John Doe"},
+    {"name": "Jane Roe", "url": "https://jane.com"}
+  ],
+  "items": [
+    {
+      "id": "post2",
+      "url": "https://blog.com/posts/2",
+      "title": "Post 2",
+      "content_html": "<p>Hello, <b>world</b></p>",
+      "summary": "Hello",
+      "image": "https://blog.com/posts/2.png",
+      "date_published": "2023-05-02T10:00:00+02:00",
+      "date_modified": "2023-05-03T10:00:00+02:00",
+      "tags": ["news", "tech"],
+      "authors": [{"name": "Jane Roe"}],
+      "attachments": [
+        {"url": "https://blog.com/posts/2.mp3", "mime_type": "audio/mpeg", "size_in_bytes": 12345}
+      ]
+    },
+    {
+      "id": 1,
+      "external_url": "https://other.com/1",
+      "content_text": "a < b",
+      "date_modified": "2023-05-01T00:00:00Z",
+      "author": {"name": "John Doe"}
+    },
+    {
+      "title": "No id nor url"
+    },
+    {
+      "id": "post2",
+      "title": "Duplicate"
+    }
+  ]
+}`
+
+func TestParse(t *testing.T) {
+	cases := map[string]struct {
+		data        string
+		contentType string
+		title       string
+		err         bool
+	}{
+		"json feed": {
+			data:        jsonFeedMock,
+			contentType: "application/feed+json; charset=utf-8",
+			title:       "Blog",
+		},
+		"json feed sniffed": {
+			data:        "\xef\xbb\xbf\n " + jsonFeedMock,
+			contentType: "text/plain",
+			title:       "Blog",
+		},
+		"json feed unknown version": {
+			data:        `{"version": "1", "title": "Blog", "items": []}`,
+			contentType: "application/json",
+			err:         true,
+		},
+		"malformed json": {
+			data:        `{"version": `,
+			contentType: "application/feed+json",
+			err:         true,
+		},
+		"rss": {
+			data:        rssContentMock,
+			contentType: "application/rss+xml",
+			title:       "FeedForAll Sample Feed",
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			feed, err := parse([]byte(c.data), c.contentType, "https://blog.com/feed.json")
+			if c.err {
+				assert.NotNil(t, err)
+			} else {
+				require.Nil(t, err)
+				assert.Equal(t, c.title, feed.Title)
+			}
+		})
+	}
+}
+
+func TestParseJsonFeed(t *testing.T) {
+	feed, err := parseJsonFeed([]byte(jsonFeedMock))
+	require.Nil(t, err)
+	assert.Equal(t, "Blog", feed.Title)
+	assert.Equal(t, "https://blog.com/", feed.Link)
+	assert.Equal(t, "The blog", feed.Description)
+	assert.Equal(t, "en", feed.Language)
+	assert.Equal(t, "John Doe, Jane Roe", feed.Author)
+	assert.Equal(t, []string{"John Doe", "Jane Roe"}, feed.Authors)
+	assert.Equal(t, "https://blog.com/icon.png", feed.Image.URL)
+	require.Equal(t, 2, len(feed.Items))
+	//
+	item := feed.Items[0]
+	assert.Equal(t, "post2", item.ID)
+	assert.Equal(t, "https://blog.com/posts/2", item.Link)
+	assert.Equal(t, "Post 2", item.Title)
+	assert.Equal(t, "<p>Hello, <b>world</b></p>", item.Content)
+	assert.Equal(t, "Hello", item.Summary)
+	assert.Equal(t, "https://blog.com/posts/2.png", item.Image.URL)
+	assert.Equal(t, time.Date(2023, 5, 2, 8, 0, 0, 0, time.UTC), item.Date.UTC())
+	assert.Equal(t, []string{"news", "tech"}, item.Categories)
+	assert.Equal(t, []*rss.Enclosure{{URL: "https://blog.com/posts/2.mp3", Type: "audio/mpeg", Length: 12345}}, item.Enclosures)
+	assert.Equal(t, ItemExt{ContentType: "text/html", SummaryType: "text/plain", Authors: []string{"Jane Roe"}}, feed.Ext(item))
+	//
+	item = feed.Items[1]
+	assert.Equal(t, "1", item.ID)
+	assert.Equal(t, "https://other.com/1", item.Link)
+	assert.Equal(t, "a < b", item.Content)
+	assert.Equal(t, time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC), item.Date.UTC())
+	assert.Equal(t, ItemExt{ContentType: "text/plain", Authors: []string{"John Doe"}}, feed.Ext(item))
+}
@@ -0,0 +1,29 @@
+package feeds
+
+import (
+	"context"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"time"
+)
+
+// Outbox keeps the converted messages until these are accepted by the output, so the messages survive the crash.
+type Outbox interface {
+	// Put persists the unsent entries. An entry with the same message id is not duplicated.
+	Put(ctx context.Context, entries []OutboxEntry) (err error)
+	// GetUnsent returns up to the limit unsent entries put before the specified time, oldest first.
+	GetUnsent(ctx context.Context, limit uint32, before time.Time) (entries []OutboxEntry, err error)
+	// MarkSent marks the entries with the specified message ids as sent. The sent entries expire after the TTL.
+	MarkSent(ctx context.Context, msgIds []string) (err error)
+	// MarkFailed counts the failed delivery attempt of the unsent entry with the specified message id. Once the max
+	// attempts count is reached, the entry is moved to the dead letters, so it's not returned by GetUnsent anymore.
+	MarkFailed(ctx context.Context, msgId string) (dead bool, err error)
+}
+
+// OutboxEntry is the converted feed item message.
+type OutboxEntry struct {
+	// Url is the feed URL.
+	Url string
+	// ItemId is the feed item identity to be marked as seen when the message is sent, see ItemId.
+	ItemId string
+	Msg    *pb.CloudEvent
+}
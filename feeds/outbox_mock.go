@@ -0,0 +1,72 @@
+package feeds
+
+import (
+	"context"
+	"time"
+)
+
+type outboxMock struct {
+	entries  *[]OutboxEntry
+	sent     map[string]bool
+	attempts map[string]uint32
+	ts       map[string]time.Time
+}
+
+// outboxMockAttemptsMax is the number of the failed delivery attempts to move the mock entry to the dead letters.
+const outboxMockAttemptsMax = 2
+
+func NewOutboxMock() Outbox {
+	return outboxMock{
+		entries:  &[]OutboxEntry{},
+		sent:     map[string]bool{},
+		attempts: map[string]uint32{},
+		ts:       map[string]time.Time{},
+	}
+}
+
+func (om outboxMock) Put(ctx context.Context, entries []OutboxEntry) (err error) {
+	for _, e := range entries {
+		if e.Url == "storage-fail" {
+			return ErrInternal
+		}
+	}
+	known := map[string]bool{}
+	for _, e := range *om.entries {
+		known[e.Msg.Id] = true
+	}
+	now := time.Now()
+	for _, e := range entries {
+		if !known[e.Msg.Id] {
+			*om.entries = append(*om.entries, e)
+			om.ts[e.Msg.Id] = now
+		}
+	}
+	return
+}
+
+func (om outboxMock) GetUnsent(ctx context.Context, limit uint32, before time.Time) (entries []OutboxEntry, err error) {
+	for _, e := range *om.entries {
+		if uint32(len(entries)) == limit {
+			break
+		}
+		if !om.sent[e.Msg.Id] && om.attempts[e.Msg.Id] < outboxMockAttemptsMax && om.ts[e.Msg.Id].Before(before) {
+			entries = append(entries, e)
+		}
+	}
+	return
+}
+
+func (om outboxMock) MarkSent(ctx context.Context, msgIds []string) (err error) {
+	for _, id := range msgIds {
+		om.sent[id] = true
+	}
+	return
+}
+
+func (om outboxMock) MarkFailed(ctx context.Context, msgId string) (dead bool, err error) {
+	if !om.sent[msgId] {
+		om.attempts[msgId]++
+		dead = om.attempts[msgId] >= outboxMockAttemptsMax
+	}
+	return
+}
@@ -0,0 +1,283 @@
+package feeds
+
+import (
+	"context"
+	"fmt"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/protobuf/proto"
+	"producer-rss/config"
+	"time"
+)
+
+type outboxMongo struct {
+	coll        *mongo.Collection
+	attemptsMax uint32
+}
+
+type outboxRec struct {
+	MsgId  string `bson:"id"`
+	Url    string `bson:"url"`
+	ItemId string `bson:"itemid"`
+	// Data is the message serialized to protobuf
+	Data       []byte    `bson:"data"`
+	CreateTime time.Time `bson:"ts"`
+	// Seq is the position in the saved batch to keep the order of the entries saved at the same time
+	Seq      int       `bson:"seq"`
+	Sent     bool      `bson:"sent"`
+	SentTime time.Time `bson:"sentts"`
+	// Attempts is the number of the failed delivery attempts
+	Attempts uint32 `bson:"attempts,omitempty"`
+	// Dead is true when the message is not delivered after the max attempts, it's not sent anymore
+	Dead bool `bson:"dead,omitempty"`
+}
+
+const attrItemId = "itemid"
+const attrData = "data"
+const attrSent = "sent"
+const attrSentTs = "sentts"
+const attrAttempts = "attempts"
+const attrDead = "dead"
+const attrSeq = "seq"
+
+var optsMarkFailed = options.
+	FindOneAndUpdate().
+	SetProjection(bson.D{
+		{
+			Key:   attrDead,
+			Value: 1,
+		},
+	}).
+	SetReturnDocument(options.After)
+var sortOldestFirst = bson.D{
+	{
+		Key:   attrTs,
+		Value: 1,
+	},
+	{
+		Key:   attrSeq,
+		Value: 1,
+	},
+}
+
+// NewOutbox returns the outbox backed by the separate table in the same DB as the feeds storage.
+func NewOutbox(ctx context.Context, db *mongo.Database, cfgDb config.DbConfig) (o Outbox, err error) {
+	om := outboxMongo{
+		coll:        db.Collection(cfgDb.Table.Outbox),
+		attemptsMax: cfgDb.Table.OutboxAttemptsMax,
+	}
+	_, err = om.ensureIndices(ctx, cfgDb.Table.OutboxTtl, cfgDb.Table.OutboxUnsentTtl)
+	if err == nil {
+		o = om
+	}
+	return
+}
+
+// ensureIndices creates the indices, the sent entries expire after the ttl since sent, any entry including the unsent
+// and dead ones expires after the unsent ttl since saved. The changed TTLs are applied to the existing indices.
+func (om outboxMongo) ensureIndices(ctx context.Context, ttl, unsentTtl time.Duration) ([]string, error) {
+	return createIndices(ctx, om.coll, []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{
+					Key:   attrId,
+					Value: 1,
+				},
+			},
+			Options: options.
+				Index().
+				SetUnique(true),
+		},
+		{
+			Keys: bson.D{
+				{
+					Key:   attrSent,
+					Value: 1,
+				},
+				{
+					Key:   attrTs,
+					Value: 1,
+				},
+				{
+					Key:   attrSeq,
+					Value: 1,
+				},
+			},
+			Options: options.
+				Index().
+				SetUnique(false),
+		},
+		{
+			Keys: bson.D{
+				{
+					Key:   attrSentTs,
+					Value: 1,
+				},
+			},
+			Options: options.
+				Index().
+				SetExpireAfterSeconds(int32(ttl / time.Second)),
+		},
+		{
+			Keys: bson.D{
+				{
+					Key:   attrTs,
+					Value: 1,
+				},
+			},
+			Options: options.
+				Index().
+				SetExpireAfterSeconds(int32(unsentTtl / time.Second)),
+		},
+	})
+}
+
+func (om outboxMongo) Put(ctx context.Context, entries []OutboxEntry) (err error) {
+	if len(entries) == 0 {
+		return
+	}
+	now := time.Now().UTC()
+	var models []mongo.WriteModel
+	for i, e := range entries {
+		var data []byte
+		data, err = proto.Marshal(e.Msg)
+		if err != nil {
+			err = fmt.Errorf("%w: %s", ErrInternal, err)
+			return
+		}
+		m := mongo.
+			NewUpdateOneModel().
+			SetFilter(bson.M{
+				attrId: e.Msg.Id,
+			}).
+			SetUpdate(bson.M{
+				"$setOnInsert": bson.M{
+					attrUrl:    e.Url,
+					attrItemId: e.ItemId,
+					attrData:   data,
+					attrTs:     now,
+					attrSeq:    i,
+					attrSent:   false,
+				},
+			}).
+			SetUpsert(true)
+		models = append(models, m)
+	}
+	_, err = om.coll.BulkWrite(ctx, models, optsWriteUnordered)
+	if err != nil {
+		err = fmt.Errorf("%w: %s", ErrInternal, err)
+	}
+	return
+}
+
+func (om outboxMongo) GetUnsent(ctx context.Context, limit uint32, before time.Time) (entries []OutboxEntry, err error) {
+	q := bson.M{
+		attrSent: false,
+		attrTs: bson.M{
+			"$lt": before.UTC(),
+		},
+		attrDead: bson.M{
+			"$ne": true,
+		},
+	}
+	var cursor *mongo.Cursor
+	optsRead := options.
+		Find().
+		SetShowRecordID(false).
+		SetSort(sortOldestFirst).
+		SetLimit(int64(limit))
+	cursor, err = om.coll.Find(ctx, q, optsRead)
+	var recs []outboxRec
+	if err == nil {
+		err = cursor.All(ctx, &recs)
+	}
+	for _, rec := range recs {
+		if err != nil {
+			break
+		}
+		msg := &pb.CloudEvent{}
+		err = proto.Unmarshal(rec.Data, msg)
+		if err == nil {
+			entries = append(entries, OutboxEntry{
+				Url:    rec.Url,
+				ItemId: rec.ItemId,
+				Msg:    msg,
+			})
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: %s", ErrInternal, err)
+	}
+	return
+}
+
+func (om outboxMongo) MarkSent(ctx context.Context, msgIds []string) (err error) {
+	if len(msgIds) == 0 {
+		return
+	}
+	q := bson.M{
+		attrId: bson.M{
+			"$in": msgIds,
+		},
+	}
+	u := bson.M{
+		"$set": bson.M{
+			attrSent:   true,
+			attrSentTs: time.Now().UTC(),
+		},
+	}
+	_, err = om.coll.UpdateMany(ctx, q, u)
+	if err != nil {
+		err = fmt.Errorf("%w: %s", ErrInternal, err)
+	}
+	return
+}
+
+func (om outboxMongo) MarkFailed(ctx context.Context, msgId string) (dead bool, err error) {
+	q := bson.M{
+		attrId:   msgId,
+		attrSent: false,
+	}
+	// increment the attempts count and check it against the max in the same update
+	attempts := bson.M{
+		"$add": bson.A{
+			bson.M{
+				"$ifNull": bson.A{
+					"$" + attrAttempts,
+					0,
+				},
+			},
+			1,
+		},
+	}
+	u := bson.A{
+		bson.M{
+			"$set": bson.M{
+				attrAttempts: attempts,
+			},
+		},
+		bson.M{
+			"$set": bson.M{
+				attrDead: bson.M{
+					"$gte": bson.A{
+						"$" + attrAttempts,
+						om.attemptsMax,
+					},
+				},
+			},
+		},
+	}
+	var rec outboxRec
+	err = om.coll.FindOneAndUpdate(ctx, q, u, optsMarkFailed).Decode(&rec)
+	switch {
+	case err == nil:
+		dead = rec.Dead
+	case err == mongo.ErrNoDocuments:
+		err = nil
+	default:
+		err = fmt.Errorf("%w: %s", ErrInternal, err)
+	}
+	return
+}
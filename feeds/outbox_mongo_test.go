@@ -0,0 +1,184 @@
+package feeds
+
+import (
+	"context"
+	"fmt"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"producer-rss/config"
+	"testing"
+	"time"
+)
+
+func TestOutboxMongo_GetUnsent(t *testing.T) {
+	//
+	collName := fmt.Sprintf("outbox-test-%d", time.Now().UnixMicro())
+	dbCfg := config.DbConfig{
+		Uri:  dbUri,
+		Name: "producer-rss",
+	}
+	dbCfg.Table.Outbox = collName
+	dbCfg.Table.OutboxTtl = time.Hour
+	dbCfg.Table.OutboxUnsentTtl = 2 * time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	o, err := NewOutbox(ctx, connectTest(ctx, t, dbCfg), dbCfg)
+	require.NotNil(t, o)
+	require.Nil(t, err)
+	om := o.(outboxMongo)
+	defer func() {
+		require.Nil(t, om.coll.Drop(ctx))
+		require.Nil(t, om.coll.Database().Client().Disconnect(ctx))
+	}()
+	//
+	var entries []OutboxEntry
+	for i := 0; i < 4; i++ {
+		entries = append(entries, OutboxEntry{
+			Url:    "https://test.rss.com",
+			ItemId: fmt.Sprintf("item%d", i),
+			Msg: &pb.CloudEvent{
+				Id:          fmt.Sprintf("msg%d", i),
+				Source:      "https://test.rss.com",
+				SpecVersion: "1.0",
+				Type:        "com.awakari.producer-rss.v1",
+			},
+		})
+	}
+	require.Nil(t, om.Put(ctx, entries[:2]))
+	// the same message is not duplicated, the entries saved at the same time keep the batch order
+	require.Nil(t, om.Put(ctx, []OutboxEntry{entries[3], entries[2], entries[1]}))
+	require.Nil(t, om.MarkSent(ctx, []string{"msg1"}))
+	now := time.Now()
+	//
+	cases := map[string]struct {
+		limit  uint32
+		before time.Time
+		ids    []string
+	}{
+		"all": {
+			limit:  10,
+			before: now,
+			ids:    []string{"msg0", "msg3", "msg2"},
+		},
+		"limit": {
+			limit:  1,
+			before: now,
+			ids:    []string{"msg0"},
+		},
+		"put later": {
+			limit:  10,
+			before: now.Add(-time.Minute),
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			unsent, err := om.GetUnsent(ctx, c.limit, c.before)
+			assert.Nil(t, err)
+			var ids []string
+			for _, e := range unsent {
+				ids = append(ids, e.Msg.Id)
+				assert.Equal(t, "https://test.rss.com", e.Url)
+				assert.Equal(t, "https://test.rss.com", e.Msg.Source)
+			}
+			assert.Equal(t, c.ids, ids)
+		})
+	}
+}
+
+func TestOutboxMongo_MarkFailed(t *testing.T) {
+	//
+	collName := fmt.Sprintf("outbox-test-%d", time.Now().UnixMicro())
+	dbCfg := config.DbConfig{
+		Uri:  dbUri,
+		Name: "producer-rss",
+	}
+	dbCfg.Table.Outbox = collName
+	dbCfg.Table.OutboxTtl = time.Hour
+	dbCfg.Table.OutboxUnsentTtl = 2 * time.Hour
+	dbCfg.Table.OutboxAttemptsMax = 2
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	o, err := NewOutbox(ctx, connectTest(ctx, t, dbCfg), dbCfg)
+	require.NotNil(t, o)
+	require.Nil(t, err)
+	om := o.(outboxMongo)
+	defer func() {
+		require.Nil(t, om.coll.Drop(ctx))
+		require.Nil(t, om.coll.Database().Client().Disconnect(ctx))
+	}()
+	//
+	var entries []OutboxEntry
+	for i := 0; i < 2; i++ {
+		entries = append(entries, OutboxEntry{
+			Url:    "https://test.rss.com",
+			ItemId: fmt.Sprintf("item%d", i),
+			Msg: &pb.CloudEvent{
+				Id:          fmt.Sprintf("msg%d", i),
+				Source:      "https://test.rss.com",
+				SpecVersion: "1.0",
+				Type:        "com.awakari.producer-rss.v1",
+			},
+		})
+	}
+	require.Nil(t, om.Put(ctx, entries))
+	//
+	dead, err := om.MarkFailed(ctx, "msg0")
+	assert.Nil(t, err)
+	assert.False(t, dead)
+	unsent, err := om.GetUnsent(ctx, 10, time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(unsent))
+	dead, err = om.MarkFailed(ctx, "msg0")
+	assert.Nil(t, err)
+	assert.True(t, dead)
+	// the dead letter is not returned anymore
+	unsent, err = om.GetUnsent(ctx, 10, time.Now())
+	assert.Nil(t, err)
+	require.Equal(t, 1, len(unsent))
+	assert.Equal(t, "msg1", unsent[0].Msg.Id)
+	// missing
+	dead, err = om.MarkFailed(ctx, "msg2")
+	assert.Nil(t, err)
+	assert.False(t, dead)
+}
+
+func TestNewOutbox_TtlChanged(t *testing.T) {
+	//
+	collName := fmt.Sprintf("outbox-test-%d", time.Now().UnixMicro())
+	dbCfg := config.DbConfig{
+		Uri:  dbUri,
+		Name: "producer-rss",
+	}
+	dbCfg.Table.Outbox = collName
+	dbCfg.Table.OutboxTtl = time.Hour
+	dbCfg.Table.OutboxUnsentTtl = 2 * time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	db := connectTest(ctx, t, dbCfg)
+	o, err := NewOutbox(ctx, db, dbCfg)
+	require.NotNil(t, o)
+	require.Nil(t, err)
+	om := o.(outboxMongo)
+	defer func() {
+		require.Nil(t, om.coll.Drop(ctx))
+		require.Nil(t, om.coll.Database().Client().Disconnect(ctx))
+	}()
+	// the existing TTL indices are updated
+	dbCfg.Table.OutboxTtl = 3 * time.Hour
+	dbCfg.Table.OutboxUnsentTtl = 4 * time.Hour
+	o, err = NewOutbox(ctx, db, dbCfg)
+	require.NotNil(t, o)
+	require.Nil(t, err)
+	var specs []*mongo.IndexSpecification
+	specs, err = om.coll.Indexes().ListSpecifications(ctx)
+	require.Nil(t, err)
+	ttls := map[string]int32{}
+	for _, spec := range specs {
+		if spec.ExpireAfterSeconds != nil {
+			ttls[spec.Name] = *spec.ExpireAfterSeconds
+		}
+	}
+	assert.Equal(t, map[string]int32{"sentts_1": 3 * 3600, "ts_1": 4 * 3600}, ttls)
+}
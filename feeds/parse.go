@@ -0,0 +1,38 @@
+package feeds
+
+import (
+	"bytes"
+	"github.com/SlyMarbo/rss"
+	"mime"
+)
+
+// bom is the UTF-8 byte order mark.
+var bom = []byte("\xef\xbb\xbf")
+
+// parse detects the feed document format and parses it: JSON Feed by the media type or by the leading "{",
+// otherwise RSS or Atom.
+func parse(data []byte, contentType string, docUrl string) (feed *Feed, err error) {
+	if isJsonFeed(data, contentType) {
+		feed, err = parseJsonFeed(data)
+	} else {
+		var rssFeed *rss.Feed
+		rssFeed, err = rss.Parse(data)
+		if err == nil {
+			feed = NewFeed(rssFeed)
+			applyXmlExt(feed, data, docUrl)
+		}
+	}
+	return
+}
+
+func isJsonFeed(data []byte, contentType string) (ok bool) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/feed+json", "application/json":
+		ok = true
+	default:
+		data = bytes.TrimPrefix(data, bom)
+		ok = bytes.HasPrefix(bytes.TrimSpace(data), []byte("{"))
+	}
+	return
+}
@@ -0,0 +1,9 @@
+package feeds
+
+// Redirect is the permanent redirect of the feed URL observed by the consecutive updates.
+type Redirect struct {
+	// Url is the redirect target URL.
+	Url string `bson:"url,omitempty"`
+	// Count is the number of the consecutive updates redirected to the same target URL.
+	Count uint32 `bson:"count,omitempty"`
+}
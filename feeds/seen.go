@@ -0,0 +1,27 @@
+package feeds
+
+import (
+	"context"
+	"github.com/SlyMarbo/rss"
+)
+
+// SeenItems keeps the identities of the feed items already produced. The identities expire after the configured TTL.
+type SeenItems interface {
+	// GetSeen returns the subset of the specified item ids already marked as seen for the feed.
+	GetSeen(ctx context.Context, url string, ids []string) (seen map[string]bool, err error)
+	// SetSeen marks the specified item ids as seen for the feed.
+	SetSeen(ctx context.Context, url string, ids []string) (err error)
+	// Migrate moves the seen item ids of the feed to the new feed URL, so the items are not produced again when the
+	// feed has moved.
+	Migrate(ctx context.Context, url, newUrl string) (err error)
+}
+
+// ItemId returns the feed item identity: the item id (RSS guid, Atom id) or the item link when there's no id.
+// Returns an empty string when the item has neither.
+func ItemId(item *rss.Item) (id string) {
+	id = item.ID
+	if id == "" {
+		id = item.Link
+	}
+	return
+}
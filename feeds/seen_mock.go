@@ -0,0 +1,61 @@
+package feeds
+
+import (
+	"context"
+)
+
+type seenItemsMock struct {
+	ids map[string]map[string]bool
+}
+
+func NewSeenItemsMock() SeenItems {
+	return seenItemsMock{
+		ids: map[string]map[string]bool{},
+	}
+}
+
+func (sim seenItemsMock) GetSeen(ctx context.Context, url string, ids []string) (seen map[string]bool, err error) {
+	switch url {
+	case "storage-fail":
+		err = ErrInternal
+	default:
+		seen = map[string]bool{}
+		for _, id := range ids {
+			if sim.ids[url][id] {
+				seen[id] = true
+			}
+		}
+	}
+	return
+}
+
+func (sim seenItemsMock) SetSeen(ctx context.Context, url string, ids []string) (err error) {
+	switch url {
+	case "storage-fail":
+		err = ErrInternal
+	default:
+		if sim.ids[url] == nil {
+			sim.ids[url] = map[string]bool{}
+		}
+		for _, id := range ids {
+			sim.ids[url][id] = true
+		}
+	}
+	return
+}
+
+func (sim seenItemsMock) Migrate(ctx context.Context, url, newUrl string) (err error) {
+	switch url {
+	case "storage-fail":
+		err = ErrInternal
+	default:
+		if sim.ids[newUrl] == nil {
+			sim.ids[newUrl] = map[string]bool{}
+		}
+		for id := range sim.ids[url] {
+			sim.ids[newUrl][id] = true
+		}
+		delete(sim.ids, url)
+	}
+	return
+}
@@ -0,0 +1,191 @@
+package feeds
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"producer-rss/config"
+	"time"
+)
+
+type seenItemsMongo struct {
+	coll *mongo.Collection
+}
+
+type seenRec struct {
+	Url string `bson:"url"`
+	Id  string `bson:"id"`
+	// SeenTime is the time when the item was produced, the record expires after the TTL since then
+	SeenTime time.Time `bson:"ts"`
+}
+
+const attrId = "id"
+
+var projReadId = bson.D{
+	{
+		Key:   attrId,
+		Value: 1,
+	},
+}
+var optsReadIds = options.
+	Find().
+	SetShowRecordID(false).
+	SetProjection(projReadId)
+var optsWriteUnordered = options.
+	BulkWrite().
+	SetOrdered(false)
+
+// NewSeenItems returns the seen items store backed by the separate table in the same DB as the feeds storage.
+func NewSeenItems(ctx context.Context, db *mongo.Database, cfgDb config.DbConfig) (si SeenItems, err error) {
+	sim := seenItemsMongo{
+		coll: db.Collection(cfgDb.Table.Seen),
+	}
+	_, err = sim.ensureIndices(ctx, cfgDb.Table.SeenTtl)
+	if err == nil {
+		si = sim
+	}
+	return
+}
+
+func (sim seenItemsMongo) ensureIndices(ctx context.Context, ttl time.Duration) ([]string, error) {
+	return createIndices(ctx, sim.coll, []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{
+					Key:   attrUrl,
+					Value: 1,
+				},
+				{
+					Key:   attrId,
+					Value: 1,
+				},
+			},
+			Options: options.
+				Index().
+				SetUnique(true),
+		},
+		{
+			Keys: bson.D{
+				{
+					Key:   attrTs,
+					Value: 1,
+				},
+			},
+			Options: options.
+				Index().
+				SetExpireAfterSeconds(int32(ttl / time.Second)),
+		},
+	})
+}
+
+func (sim seenItemsMongo) GetSeen(ctx context.Context, url string, ids []string) (seen map[string]bool, err error) {
+	seen = map[string]bool{}
+	if len(ids) == 0 {
+		return
+	}
+	q := bson.M{
+		attrUrl: url,
+		attrId: bson.M{
+			"$in": ids,
+		},
+	}
+	var cursor *mongo.Cursor
+	cursor, err = sim.coll.Find(ctx, q, optsReadIds)
+	var recs []seenRec
+	if err == nil {
+		err = cursor.All(ctx, &recs)
+	}
+	if err == nil {
+		for _, rec := range recs {
+			seen[rec.Id] = true
+		}
+	} else {
+		err = fmt.Errorf("%w: %s", ErrInternal, err)
+	}
+	return
+}
+
+func (sim seenItemsMongo) SetSeen(ctx context.Context, url string, ids []string) (err error) {
+	if len(ids) == 0 {
+		return
+	}
+	now := time.Now().UTC()
+	var models []mongo.WriteModel
+	for _, id := range ids {
+		m := mongo.
+			NewUpdateOneModel().
+			SetFilter(bson.M{
+				attrUrl: url,
+				attrId:  id,
+			}).
+			SetUpdate(bson.M{
+				"$set": bson.M{
+					attrTs: now,
+				},
+			}).
+			SetUpsert(true)
+		models = append(models, m)
+	}
+	_, err = sim.coll.BulkWrite(ctx, models, optsWriteUnordered)
+	if err != nil {
+		err = fmt.Errorf("%w: %s", ErrInternal, err)
+	}
+	return
+}
+
+func (sim seenItemsMongo) Migrate(ctx context.Context, url, newUrl string) (err error) {
+	q := bson.M{
+		attrUrl: url,
+	}
+	u := bson.M{
+		"$set": bson.M{
+			attrUrl: newUrl,
+		},
+	}
+	_, err = sim.coll.UpdateMany(ctx, q, u)
+	if mongo.IsDuplicateKeyError(err) {
+		// some items are seen for the new URL already, merge the rest and drop the old records
+		err = sim.merge(ctx, url, newUrl)
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: %s", ErrInternal, err)
+	}
+	return
+}
+
+func (sim seenItemsMongo) merge(ctx context.Context, url, newUrl string) (err error) {
+	q := bson.M{
+		attrUrl: url,
+	}
+	var cursor *mongo.Cursor
+	cursor, err = sim.coll.Find(ctx, q)
+	var recs []seenRec
+	if err == nil {
+		err = cursor.All(ctx, &recs)
+	}
+	var models []mongo.WriteModel
+	for _, rec := range recs {
+		m := mongo.
+			NewUpdateOneModel().
+			SetFilter(bson.M{
+				attrUrl: newUrl,
+				attrId:  rec.Id,
+			}).
+			SetUpdate(bson.M{
+				"$setOnInsert": bson.M{
+					attrTs: rec.SeenTime,
+				},
+			}).
+			SetUpsert(true)
+		models = append(models, m)
+	}
+	if err == nil && len(models) > 0 {
+		_, err = sim.coll.BulkWrite(ctx, models, optsWriteUnordered)
+	}
+	if err == nil {
+		_, err = sim.coll.DeleteMany(ctx, q)
+	}
+	return
+}
@@ -0,0 +1,163 @@
+package feeds
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"producer-rss/config"
+	"testing"
+	"time"
+)
+
+func TestSeenItemsMongo_GetSeen(t *testing.T) {
+	//
+	collName := fmt.Sprintf("seen-test-%d", time.Now().UnixMicro())
+	dbCfg := config.DbConfig{
+		Uri:  dbUri,
+		Name: "producer-rss",
+	}
+	dbCfg.Table.Seen = collName
+	dbCfg.Table.SeenTtl = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	si, err := NewSeenItems(ctx, connectTest(ctx, t, dbCfg), dbCfg)
+	require.NotNil(t, si)
+	require.Nil(t, err)
+	sim := si.(seenItemsMongo)
+	defer func() {
+		require.Nil(t, sim.coll.Drop(ctx))
+		require.Nil(t, sim.coll.Database().Client().Disconnect(ctx))
+	}()
+	//
+	err = sim.SetSeen(ctx, "https://test.rss.com", []string{"item0", "item1"})
+	require.Nil(t, err)
+	// idempotent
+	err = sim.SetSeen(ctx, "https://test.rss.com", []string{"item1"})
+	require.Nil(t, err)
+	//
+	cases := map[string]struct {
+		url  string
+		ids  []string
+		seen map[string]bool
+	}{
+		"empty": {
+			url:  "https://test.rss.com",
+			seen: map[string]bool{},
+		},
+		"partially seen": {
+			url: "https://test.rss.com",
+			ids: []string{"item1", "item2"},
+			seen: map[string]bool{
+				"item1": true,
+			},
+		},
+		"other feed": {
+			url:  "https://other.rss.com",
+			ids:  []string{"item0", "item1"},
+			seen: map[string]bool{},
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			seen, err := sim.GetSeen(ctx, c.url, c.ids)
+			assert.Nil(t, err)
+			assert.Equal(t, c.seen, seen)
+		})
+	}
+}
+
+func TestNewSeenItems_TtlChanged(t *testing.T) {
+	//
+	collName := fmt.Sprintf("seen-test-%d", time.Now().UnixMicro())
+	dbCfg := config.DbConfig{
+		Uri:  dbUri,
+		Name: "producer-rss",
+	}
+	dbCfg.Table.Seen = collName
+	dbCfg.Table.SeenTtl = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	db := connectTest(ctx, t, dbCfg)
+	si, err := NewSeenItems(ctx, db, dbCfg)
+	require.NotNil(t, si)
+	require.Nil(t, err)
+	sim := si.(seenItemsMongo)
+	defer func() {
+		require.Nil(t, sim.coll.Drop(ctx))
+		require.Nil(t, sim.coll.Database().Client().Disconnect(ctx))
+	}()
+	// the existing TTL index is updated
+	dbCfg.Table.SeenTtl = 2 * time.Hour
+	si, err = NewSeenItems(ctx, db, dbCfg)
+	require.NotNil(t, si)
+	require.Nil(t, err)
+	var specs []*mongo.IndexSpecification
+	specs, err = sim.coll.Indexes().ListSpecifications(ctx)
+	require.Nil(t, err)
+	var ttl int32
+	for _, spec := range specs {
+		if spec.ExpireAfterSeconds != nil {
+			ttl = *spec.ExpireAfterSeconds
+		}
+	}
+	assert.Equal(t, int32(2*time.Hour/time.Second), ttl)
+}
+
+func TestSeenItemsMongo_Migrate(t *testing.T) {
+	//
+	collName := fmt.Sprintf("seen-test-%d", time.Now().UnixMicro())
+	dbCfg := config.DbConfig{
+		Uri:  dbUri,
+		Name: "producer-rss",
+	}
+	dbCfg.Table.Seen = collName
+	dbCfg.Table.SeenTtl = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	si, err := NewSeenItems(ctx, connectTest(ctx, t, dbCfg), dbCfg)
+	require.NotNil(t, si)
+	require.Nil(t, err)
+	sim := si.(seenItemsMongo)
+	defer func() {
+		require.Nil(t, sim.coll.Drop(ctx))
+		require.Nil(t, sim.coll.Database().Client().Disconnect(ctx))
+	}()
+	//
+	require.Nil(t, sim.SetSeen(ctx, "https://old.rss.com", []string{"item0", "item1"}))
+	require.Nil(t, sim.SetSeen(ctx, "https://old2.rss.com", []string{"item1", "item2"}))
+	require.Nil(t, sim.SetSeen(ctx, "https://new.rss.com", []string{"item2"}))
+	require.Nil(t, sim.Migrate(ctx, "https://old.rss.com", "https://new.rss.com"))
+	// some items are seen for the new URL already
+	require.Nil(t, sim.Migrate(ctx, "https://old2.rss.com", "https://new.rss.com"))
+	//
+	cases := map[string]struct {
+		url  string
+		seen map[string]bool
+	}{
+		"new": {
+			url: "https://new.rss.com",
+			seen: map[string]bool{
+				"item0": true,
+				"item1": true,
+				"item2": true,
+			},
+		},
+		"old": {
+			url:  "https://old.rss.com",
+			seen: map[string]bool{},
+		},
+		"old merged": {
+			url:  "https://old2.rss.com",
+			seen: map[string]bool{},
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			seen, err := sim.GetSeen(ctx, c.url, []string{"item0", "item1", "item2"})
+			assert.Nil(t, err)
+			assert.Equal(t, c.seen, seen)
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package feeds
+
+// State is the feed health state.
+type State string
+
+const (
+	// StateActive means the last feed update succeeded.
+	StateActive State = "active"
+	// StateFailing means the last feed update failed, but the feed is still updated.
+	StateFailing State = "failing"
+	// StateDisabled means the feed was missing or unparseable for too many consecutive updates.
+	StateDisabled State = "disabled"
+	// StateGone means the feed server responded with 410 Gone.
+	StateGone State = "gone"
+)
+
+// Status is the feed health status.
+type Status struct {
+	// State is empty for the feed that was never updated, same as active.
+	State State `bson:"state,omitempty"`
+	// Failures is the number of the consecutive failed updates.
+	Failures uint32 `bson:"failures,omitempty"`
+	// DeadFailures is the number of the consecutive updates failed because the feed is missing or unparseable.
+	DeadFailures uint32 `bson:"deadfailures,omitempty"`
+	// LastError is the last update failure description.
+	LastError string `bson:"lasterr,omitempty"`
+}
+
+// Retired returns true if the feed should not be updated anymore unless re-enabled explicitly.
+func (s Status) Retired() bool {
+	return s.State == StateDisabled || s.State == StateGone
+}
@@ -3,14 +3,39 @@ package feeds
 import (
 	"context"
 	"errors"
-	"io"
 	"time"
 )
 
 type Storage interface {
-	io.Closer
-	GetUpdateTime(ctx context.Context, url string) (t time.Time, err error)
+	// Get returns the record of the feed by the URL or of the feed migrated from the URL before, so the record URL
+	// differs from the specified one in the latter case. Returns the record with the specified URL only if the feed is
+	// not known yet.
+	Get(ctx context.Context, url string) (rec Record, err error)
 	SetUpdateTime(ctx context.Context, url string, t time.Time) (err error)
+	SetUpdateInterval(ctx context.Context, url string, d time.Duration) (err error)
+	SetValidators(ctx context.Context, url string, v Validators) (err error)
+	SetRedirect(ctx context.Context, url string, r Redirect) (err error)
+	SetStatus(ctx context.Context, url string, s Status) (err error)
+	// ResetStatus clears the status of the known feed by the URL or the URL the feed was migrated from, so the retired
+	// feed is updated again. Returns ErrUnknownFeed when there's no such feed record.
+	ResetStatus(ctx context.Context, url string) (err error)
+	// Migrate moves the feed record to the new URL, the previous URL is kept to be resolved by the Get.
+	Migrate(ctx context.Context, url, newUrl string) (err error)
+}
+
+// Record is the feed state persisted between the updates.
+type Record struct {
+	Url            string        `bson:"url"`
+	UpdateTime     time.Time     `bson:"ts"`
+	UpdateInterval time.Duration `bson:"interval,omitempty"`
+	Validators     Validators    `bson:"validators,omitempty"`
+	Redirect       Redirect      `bson:"redirect,omitempty"`
+	// PrevUrls are the URLs the feed was migrated from.
+	PrevUrls []string `bson:"prevurls,omitempty"`
+	Status   Status   `bson:"status,omitempty"`
 }
 
 var ErrInternal = errors.New("internal failure")
+
+// ErrUnknownFeed means there's no record of the feed, it was never updated.
+var ErrUnknownFeed = errors.New("unknown feed")
@@ -0,0 +1,134 @@
+package feeds
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type storageMock struct {
+	updTimes     map[string]time.Time
+	updIntervals map[string]time.Duration
+	validators   map[string]Validators
+	redirects    map[string]Redirect
+	migrations   map[string]string
+	statuses     map[string]Status
+}
+
+func NewStorageMock(updTimes map[string]time.Time) Storage {
+	return storageMock{
+		updTimes:     updTimes,
+		updIntervals: map[string]time.Duration{},
+		validators:   map[string]Validators{},
+		redirects:    map[string]Redirect{},
+		migrations:   map[string]string{},
+		statuses:     map[string]Status{},
+	}
+}
+
+func (sm storageMock) Get(ctx context.Context, url string) (rec Record, err error) {
+	switch url {
+	case "storage-fail":
+		err = ErrInternal
+	default:
+		if newUrl, ok := sm.migrations[url]; ok {
+			url = newUrl
+		}
+		rec = Record{
+			Url:            url,
+			UpdateTime:     sm.updTimes[url],
+			UpdateInterval: sm.updIntervals[url],
+			Validators:     sm.validators[url],
+			Redirect:       sm.redirects[url],
+			Status:         sm.statuses[url],
+		}
+	}
+	return
+}
+
+func (sm storageMock) SetUpdateTime(ctx context.Context, url string, t time.Time) (err error) {
+	switch url {
+	case "storage-fail":
+		err = ErrInternal
+	default:
+		sm.updTimes[url] = t
+	}
+	return
+}
+
+func (sm storageMock) SetUpdateInterval(ctx context.Context, url string, d time.Duration) (err error) {
+	switch url {
+	case "storage-fail":
+		err = ErrInternal
+	default:
+		sm.updIntervals[url] = d
+	}
+	return
+}
+
+func (sm storageMock) SetValidators(ctx context.Context, url string, v Validators) (err error) {
+	switch url {
+	case "storage-fail":
+		err = ErrInternal
+	default:
+		sm.validators[url] = v
+	}
+	return
+}
+
+func (sm storageMock) SetRedirect(ctx context.Context, url string, r Redirect) (err error) {
+	switch url {
+	case "storage-fail":
+		err = ErrInternal
+	default:
+		sm.redirects[url] = r
+	}
+	return
+}
+
+func (sm storageMock) SetStatus(ctx context.Context, url string, s Status) (err error) {
+	switch url {
+	case "storage-fail":
+		err = ErrInternal
+	default:
+		sm.statuses[url] = s
+	}
+	return
+}
+
+func (sm storageMock) ResetStatus(ctx context.Context, url string) (err error) {
+	if newUrl, ok := sm.migrations[url]; ok {
+		url = newUrl
+	}
+	// the feed record is created by the first update, either successful or not
+	_, updated := sm.updTimes[url]
+	_, tracked := sm.statuses[url]
+	switch {
+	case url == "storage-fail":
+		err = ErrInternal
+	case !updated && !tracked:
+		err = fmt.Errorf("%w: %s", ErrUnknownFeed, url)
+	default:
+		delete(sm.statuses, url)
+	}
+	return
+}
+
+func (sm storageMock) Migrate(ctx context.Context, url, newUrl string) (err error) {
+	switch url {
+	case "storage-fail":
+		err = ErrInternal
+	default:
+		sm.updTimes[newUrl] = sm.updTimes[url]
+		delete(sm.updTimes, url)
+		sm.updIntervals[newUrl] = sm.updIntervals[url]
+		delete(sm.updIntervals, url)
+		sm.validators[newUrl] = sm.validators[url]
+		delete(sm.validators, url)
+		delete(sm.redirects, url)
+		sm.statuses[newUrl] = sm.statuses[url]
+		delete(sm.statuses, url)
+		sm.migrations[url] = newUrl
+	}
+	return
+}
@@ -3,6 +3,7 @@ package feeds
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -12,30 +13,27 @@ import (
 )
 
 type storageMongo struct {
-	conn *mongo.Client
-	db   *mongo.Database
 	coll *mongo.Collection
 }
 
-type feedRec struct {
-	Url        string    `bson:"url"`
-	UpdateTime time.Time `bson:"ts"`
-}
-
 const attrUrl = "url"
 const attrTs = "ts"
+const attrInterval = "interval"
+const attrValidators = "validators"
+const attrRedirect = "redirect"
+const attrPrevUrls = "prevurls"
+const attrStatus = "status"
+
+// codeIndexOptionsConflict is returned when the index with the same keys exists but has the different options.
+const codeIndexOptionsConflict = 85
+
+// codeIndexNotFound is returned when the index to modify doesn't exist.
+const codeIndexNotFound = 27
 
-var projRead = bson.D{
-	{
-		Key:   attrTs,
-		Value: 1,
-	},
-}
 var optsSrvApi = options.ServerAPI(options.ServerAPIVersion1)
 var optsRead = options.
 	FindOne().
-	SetShowRecordID(false).
-	SetProjection(projRead)
+	SetShowRecordID(false)
 var optsUpsert = options.
 	Update().
 	SetUpsert(true)
@@ -51,9 +49,34 @@ var indices = []mongo.IndexModel{
 			Index().
 			SetUnique(true),
 	},
+	{
+		Keys: bson.D{
+			{
+				Key:   attrPrevUrls,
+				Value: 1,
+			},
+		},
+		Options: options.
+			Index().
+			SetUnique(false),
+	},
 }
 
-func NewStorage(ctx context.Context, cfgDb config.DbConfig) (s Storage, err error) {
+// NewStorage returns the feeds storage backed by the table in the specified DB.
+func NewStorage(ctx context.Context, db *mongo.Database, cfgDb config.DbConfig) (s Storage, err error) {
+	sm := storageMongo{
+		coll: db.Collection(cfgDb.Table.Name),
+	}
+	_, err = sm.ensureIndices(ctx)
+	if err == nil {
+		s = sm
+	}
+	return
+}
+
+// Connect opens the DB connection pool to be shared by the feeds storage, the seen items store and the outbox.
+// The caller should disconnect the DB client when done.
+func Connect(ctx context.Context, cfgDb config.DbConfig) (db *mongo.Database, err error) {
 	clientOpts := options.
 		Client().
 		ApplyURI(cfgDb.Uri).
@@ -69,18 +92,10 @@ func NewStorage(ctx context.Context, cfgDb config.DbConfig) (s Storage, err erro
 		}
 		clientOpts = clientOpts.SetAuth(auth)
 	}
-	conn, err := mongo.Connect(ctx, clientOpts)
-	var sm storageMongo
+	var conn *mongo.Client
+	conn, err = mongo.Connect(ctx, clientOpts)
 	if err == nil {
-		db := conn.Database(cfgDb.Name)
-		coll := db.Collection(cfgDb.Table.Name)
-		sm.conn = conn
-		sm.db = db
-		sm.coll = coll
-		_, err = sm.ensureIndices(ctx)
-	}
-	if err == nil {
-		s = sm
+		db = conn.Database(cfgDb.Name)
 	}
 	return
 }
@@ -89,23 +104,76 @@ func (sm storageMongo) ensureIndices(ctx context.Context) ([]string, error) {
 	return sm.coll.Indexes().CreateMany(ctx, indices)
 }
 
-func (sm storageMongo) Close() error {
-	return sm.conn.Disconnect(context.TODO())
+// createIndices creates the indices. When the index exists with the different TTL, e.g. the configured one has changed,
+// the TTL is updated in place, as the index creation fails on the options conflict.
+func createIndices(ctx context.Context, coll *mongo.Collection, models []mongo.IndexModel) (names []string, err error) {
+	names, err = coll.Indexes().CreateMany(ctx, models)
+	var errSrv mongo.ServerError
+	if errors.As(err, &errSrv) && errSrv.HasErrorCode(codeIndexOptionsConflict) {
+		err = updateTtls(ctx, coll, models)
+		if err == nil {
+			names, err = coll.Indexes().CreateMany(ctx, models)
+		}
+	}
+	return
+}
+
+func updateTtls(ctx context.Context, coll *mongo.Collection, models []mongo.IndexModel) (err error) {
+	for _, m := range models {
+		if m.Options == nil || m.Options.ExpireAfterSeconds == nil {
+			continue
+		}
+		cmd := bson.D{
+			{
+				Key:   "collMod",
+				Value: coll.Name(),
+			},
+			{
+				Key: "index",
+				Value: bson.D{
+					{
+						Key:   "keyPattern",
+						Value: m.Keys,
+					},
+					{
+						Key:   "expireAfterSeconds",
+						Value: *m.Options.ExpireAfterSeconds,
+					},
+				},
+			},
+		}
+		err = coll.Database().RunCommand(ctx, cmd).Err()
+		var errSrv mongo.ServerError
+		if errors.As(err, &errSrv) && errSrv.HasErrorCode(codeIndexNotFound) {
+			// not created yet, nothing to update
+			err = nil
+		}
+		if err != nil {
+			break
+		}
+	}
+	return
 }
 
-func (sm storageMongo) GetUpdateTime(ctx context.Context, url string) (t time.Time, err error) {
+func (sm storageMongo) Get(ctx context.Context, url string) (rec Record, err error) {
 	q := bson.M{
-		attrUrl: url,
+		"$or": bson.A{
+			bson.M{
+				attrUrl: url,
+			},
+			bson.M{
+				attrPrevUrls: url,
+			},
+		},
 	}
 	var result *mongo.SingleResult
 	result = sm.coll.FindOne(ctx, q, optsRead)
-	var rec feedRec
 	err = result.Decode(&rec)
-	if err == nil {
-		t = rec.UpdateTime
-	}
 	switch {
 	case err == mongo.ErrNoDocuments:
+		rec = Record{
+			Url: url,
+		}
 		err = nil
 	case err != nil:
 		err = fmt.Errorf("%w: %s", ErrInternal, err)
@@ -128,3 +196,131 @@ func (sm storageMongo) SetUpdateTime(ctx context.Context, url string, t time.Tim
 	}
 	return
 }
+
+func (sm storageMongo) SetUpdateInterval(ctx context.Context, url string, d time.Duration) (err error) {
+	q := bson.M{
+		attrUrl: url,
+	}
+	u := bson.M{
+		"$set": bson.M{
+			attrInterval: d,
+		},
+	}
+	_, err = sm.coll.UpdateOne(ctx, q, u, optsUpsert)
+	if err != nil {
+		err = fmt.Errorf("%w: %s", ErrInternal, err)
+	}
+	return
+}
+
+func (sm storageMongo) SetValidators(ctx context.Context, url string, v Validators) (err error) {
+	q := bson.M{
+		attrUrl: url,
+	}
+	u := bson.M{
+		"$set": bson.M{
+			attrValidators: v,
+		},
+	}
+	_, err = sm.coll.UpdateOne(ctx, q, u, optsUpsert)
+	if err != nil {
+		err = fmt.Errorf("%w: %s", ErrInternal, err)
+	}
+	return
+}
+
+func (sm storageMongo) SetRedirect(ctx context.Context, url string, r Redirect) (err error) {
+	q := bson.M{
+		attrUrl: url,
+	}
+	u := bson.M{
+		"$set": bson.M{
+			attrRedirect: r,
+		},
+	}
+	_, err = sm.coll.UpdateOne(ctx, q, u, optsUpsert)
+	if err != nil {
+		err = fmt.Errorf("%w: %s", ErrInternal, err)
+	}
+	return
+}
+
+func (sm storageMongo) SetStatus(ctx context.Context, url string, s Status) (err error) {
+	q := bson.M{
+		attrUrl: url,
+	}
+	u := bson.M{
+		"$set": bson.M{
+			attrStatus: s,
+		},
+	}
+	_, err = sm.coll.UpdateOne(ctx, q, u, optsUpsert)
+	if err != nil {
+		err = fmt.Errorf("%w: %s", ErrInternal, err)
+	}
+	return
+}
+
+func (sm storageMongo) ResetStatus(ctx context.Context, url string) (err error) {
+	q := bson.M{
+		"$or": bson.A{
+			bson.M{
+				attrUrl: url,
+			},
+			bson.M{
+				attrPrevUrls: url,
+			},
+		},
+	}
+	u := bson.M{
+		"$unset": bson.M{
+			attrStatus: "",
+		},
+	}
+	var result *mongo.UpdateResult
+	result, err = sm.coll.UpdateOne(ctx, q, u)
+	switch {
+	case err != nil:
+		err = fmt.Errorf("%w: %s", ErrInternal, err)
+	case result.MatchedCount == 0:
+		err = fmt.Errorf("%w: %s", ErrUnknownFeed, url)
+	}
+	return
+}
+
+func (sm storageMongo) Migrate(ctx context.Context, url, newUrl string) (err error) {
+	q := bson.M{
+		attrUrl: url,
+	}
+	u := bson.M{
+		"$set": bson.M{
+			attrUrl: newUrl,
+		},
+		"$unset": bson.M{
+			attrRedirect: "",
+		},
+		"$addToSet": bson.M{
+			attrPrevUrls: url,
+		},
+	}
+	_, err = sm.coll.UpdateOne(ctx, q, u)
+	if mongo.IsDuplicateKeyError(err) {
+		// the feed record for the new URL already exists, keep it and drop the old one
+		qNew := bson.M{
+			attrUrl: newUrl,
+		}
+		uNew := bson.M{
+			"$addToSet": bson.M{
+				attrPrevUrls: url,
+			},
+		}
+		_, err = sm.coll.UpdateOne(ctx, qNew, uNew)
+		if err == nil {
+			_, err = sm.coll.DeleteOne(ctx, q)
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: %s", ErrInternal, err)
+	}
+	return
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 	"os"
 	"producer-rss/config"
 	"testing"
@@ -23,19 +25,25 @@ func TestNewStorage(t *testing.T) {
 	dbCfg.Table.Name = collName
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
 	defer cancel()
-	s, err := NewStorage(ctx, dbCfg)
+	s, err := NewStorage(ctx, connectTest(ctx, t, dbCfg), dbCfg)
 	assert.NotNil(t, s)
 	assert.Nil(t, err)
 	//
 	clear(ctx, t, s.(storageMongo))
 }
 
+func connectTest(ctx context.Context, t *testing.T, cfgDb config.DbConfig) (db *mongo.Database) {
+	db, err := Connect(ctx, cfgDb)
+	require.Nil(t, err)
+	return
+}
+
 func clear(ctx context.Context, t *testing.T, sm storageMongo) {
 	require.Nil(t, sm.coll.Drop(ctx))
-	require.Nil(t, sm.Close())
+	require.Nil(t, sm.coll.Database().Client().Disconnect(ctx))
 }
 
-func TestStorageMongo_GetUpdateTime(t *testing.T) {
+func TestStorageMongo_Get(t *testing.T) {
 	//
 	collName := fmt.Sprintf("feeds-test-%d", time.Now().UnixMicro())
 	dbCfg := config.DbConfig{
@@ -45,36 +53,91 @@ func TestStorageMongo_GetUpdateTime(t *testing.T) {
 	dbCfg.Table.Name = collName
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
 	defer cancel()
-	s, err := NewStorage(ctx, dbCfg)
+	s, err := NewStorage(ctx, connectTest(ctx, t, dbCfg), dbCfg)
 	require.NotNil(t, s)
 	require.Nil(t, err)
 	sm := s.(storageMongo)
 	defer clear(ctx, t, sm)
 	//
-	_, err = sm.coll.InsertOne(ctx, feedRec{
-		Url:        "https://test.rss.com",
-		UpdateTime: time.Date(2023, 5, 23, 8, 52, 40, 0, time.UTC),
+	_, err = sm.coll.InsertMany(ctx, []interface{}{
+		Record{
+			Url:            "https://test0.rss.com",
+			UpdateTime:     time.Date(2023, 5, 23, 8, 52, 40, 0, time.UTC),
+			UpdateInterval: 15 * time.Minute,
+			Validators: Validators{
+				ETag:         `"5e2a-5fe4"`,
+				LastModified: "Tue, 23 May 2023 08:52:40 GMT",
+			},
+			Status: Status{
+				State:     StateFailing,
+				Failures:  1,
+				LastError: "not found",
+			},
+		},
+		Record{
+			Url:        "https://test1.rss.com",
+			UpdateTime: time.Date(2023, 5, 23, 8, 52, 40, 0, time.UTC),
+			PrevUrls: []string{
+				"https://test1.rss.org",
+			},
+		},
 	})
 	require.Nil(t, err)
 	//
 	cases := map[string]struct {
 		url string
-		ut  time.Time
+		rec Record
 	}{
 		"found": {
-			url: "https://test.rss.com",
-			ut:  time.Date(2023, 5, 23, 8, 52, 40, 0, time.UTC),
+			url: "https://test0.rss.com",
+			rec: Record{
+				Url:            "https://test0.rss.com",
+				UpdateTime:     time.Date(2023, 5, 23, 8, 52, 40, 0, time.UTC),
+				UpdateInterval: 15 * time.Minute,
+				Validators: Validators{
+					ETag:         `"5e2a-5fe4"`,
+					LastModified: "Tue, 23 May 2023 08:52:40 GMT",
+				},
+				Status: Status{
+					State:     StateFailing,
+					Failures:  1,
+					LastError: "not found",
+				},
+			},
+		},
+		"interval not learned yet": {
+			url: "https://test1.rss.com",
+			rec: Record{
+				Url:        "https://test1.rss.com",
+				UpdateTime: time.Date(2023, 5, 23, 8, 52, 40, 0, time.UTC),
+				PrevUrls: []string{
+					"https://test1.rss.org",
+				},
+			},
+		},
+		"migrated": {
+			url: "https://test1.rss.org",
+			rec: Record{
+				Url:        "https://test1.rss.com",
+				UpdateTime: time.Date(2023, 5, 23, 8, 52, 40, 0, time.UTC),
+				PrevUrls: []string{
+					"https://test1.rss.org",
+				},
+			},
 		},
 		"not found": {
 			url: "https://missing.com",
+			rec: Record{
+				Url: "https://missing.com",
+			},
 		},
 	}
 	//
 	for k, c := range cases {
 		t.Run(k, func(t *testing.T) {
-			var ut time.Time
-			ut, err = sm.GetUpdateTime(ctx, c.url)
-			assert.Equal(t, c.ut, ut)
+			var rec Record
+			rec, err = sm.Get(ctx, c.url)
+			assert.Equal(t, c.rec, rec)
 			assert.Nil(t, err)
 		})
 	}
@@ -90,13 +153,13 @@ func TestStorageMongo_SetUpdateTime(t *testing.T) {
 	dbCfg.Table.Name = collName
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
 	defer cancel()
-	s, err := NewStorage(ctx, dbCfg)
+	s, err := NewStorage(ctx, connectTest(ctx, t, dbCfg), dbCfg)
 	require.NotNil(t, s)
 	require.Nil(t, err)
 	sm := s.(storageMongo)
 	defer clear(ctx, t, sm)
 	//
-	_, err = sm.coll.InsertOne(ctx, feedRec{
+	_, err = sm.coll.InsertOne(ctx, Record{
 		Url:        "https://test0.rss.com",
 		UpdateTime: time.Date(2023, 5, 23, 8, 52, 40, 0, time.UTC),
 	})
@@ -123,3 +186,368 @@ func TestStorageMongo_SetUpdateTime(t *testing.T) {
 		})
 	}
 }
+
+func TestStorageMongo_SetUpdateInterval(t *testing.T) {
+	//
+	collName := fmt.Sprintf("feeds-test-%d", time.Now().UnixMicro())
+	dbCfg := config.DbConfig{
+		Uri:  dbUri,
+		Name: "producer-rss",
+	}
+	dbCfg.Table.Name = collName
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	s, err := NewStorage(ctx, connectTest(ctx, t, dbCfg), dbCfg)
+	require.NotNil(t, s)
+	require.Nil(t, err)
+	sm := s.(storageMongo)
+	defer clear(ctx, t, sm)
+	//
+	_, err = sm.coll.InsertOne(ctx, Record{
+		Url:        "https://test0.rss.com",
+		UpdateTime: time.Date(2023, 5, 23, 8, 52, 40, 0, time.UTC),
+	})
+	require.Nil(t, err)
+	//
+	cases := map[string]struct {
+		url string
+		d   time.Duration
+	}{
+		"existing": {
+			url: "https://test0.rss.com",
+			d:   1 * time.Hour,
+		},
+		"new": {
+			url: "https://test1.rss.com",
+			d:   2 * time.Minute,
+		},
+	}
+	//
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			err = sm.SetUpdateInterval(ctx, c.url, c.d)
+			assert.Nil(t, err)
+			var rec Record
+			rec, err = sm.Get(ctx, c.url)
+			assert.Equal(t, c.d, rec.UpdateInterval)
+			assert.Nil(t, err)
+		})
+	}
+	// the update time should be kept
+	var rec Record
+	rec, err = sm.Get(ctx, "https://test0.rss.com")
+	assert.Equal(t, time.Date(2023, 5, 23, 8, 52, 40, 0, time.UTC), rec.UpdateTime)
+	assert.Nil(t, err)
+}
+
+func TestStorageMongo_SetValidators(t *testing.T) {
+	//
+	collName := fmt.Sprintf("feeds-test-%d", time.Now().UnixMicro())
+	dbCfg := config.DbConfig{
+		Uri:  dbUri,
+		Name: "producer-rss",
+	}
+	dbCfg.Table.Name = collName
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	s, err := NewStorage(ctx, connectTest(ctx, t, dbCfg), dbCfg)
+	require.NotNil(t, s)
+	require.Nil(t, err)
+	sm := s.(storageMongo)
+	defer clear(ctx, t, sm)
+	//
+	_, err = sm.coll.InsertOne(ctx, Record{
+		Url:        "https://test0.rss.com",
+		UpdateTime: time.Date(2023, 5, 23, 8, 52, 40, 0, time.UTC),
+		Validators: Validators{
+			ETag: `"5e2a-5fe4"`,
+		},
+	})
+	require.Nil(t, err)
+	//
+	cases := map[string]struct {
+		url string
+		v   Validators
+	}{
+		"existing": {
+			url: "https://test0.rss.com",
+			v: Validators{
+				LastModified: "Tue, 23 May 2023 09:00:40 GMT",
+			},
+		},
+		"new": {
+			url: "https://test1.rss.com",
+			v: Validators{
+				ETag: `"5e2a-6000"`,
+			},
+		},
+	}
+	//
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			err = sm.SetValidators(ctx, c.url, c.v)
+			assert.Nil(t, err)
+			var rec Record
+			rec, err = sm.Get(ctx, c.url)
+			assert.Equal(t, c.v, rec.Validators)
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestStorageMongo_SetRedirect(t *testing.T) {
+	//
+	collName := fmt.Sprintf("feeds-test-%d", time.Now().UnixMicro())
+	dbCfg := config.DbConfig{
+		Uri:  dbUri,
+		Name: "producer-rss",
+	}
+	dbCfg.Table.Name = collName
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	s, err := NewStorage(ctx, connectTest(ctx, t, dbCfg), dbCfg)
+	require.NotNil(t, s)
+	require.Nil(t, err)
+	sm := s.(storageMongo)
+	defer clear(ctx, t, sm)
+	//
+	_, err = sm.coll.InsertOne(ctx, Record{
+		Url:        "https://test0.rss.com",
+		UpdateTime: time.Date(2023, 5, 23, 8, 52, 40, 0, time.UTC),
+	})
+	require.Nil(t, err)
+	//
+	cases := map[string]struct {
+		url string
+		r   Redirect
+	}{
+		"existing": {
+			url: "https://test0.rss.com",
+			r: Redirect{
+				Url:   "https://test0.rss.org",
+				Count: 2,
+			},
+		},
+		"new": {
+			url: "https://test1.rss.com",
+			r: Redirect{
+				Url:   "https://test1.rss.org",
+				Count: 1,
+			},
+		},
+	}
+	//
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			err = sm.SetRedirect(ctx, c.url, c.r)
+			assert.Nil(t, err)
+			var rec Record
+			rec, err = sm.Get(ctx, c.url)
+			assert.Equal(t, c.r, rec.Redirect)
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestStorageMongo_Migrate(t *testing.T) {
+	//
+	collName := fmt.Sprintf("feeds-test-%d", time.Now().UnixMicro())
+	dbCfg := config.DbConfig{
+		Uri:  dbUri,
+		Name: "producer-rss",
+	}
+	dbCfg.Table.Name = collName
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	s, err := NewStorage(ctx, connectTest(ctx, t, dbCfg), dbCfg)
+	require.NotNil(t, s)
+	require.Nil(t, err)
+	sm := s.(storageMongo)
+	defer clear(ctx, t, sm)
+	//
+	_, err = sm.coll.InsertMany(ctx, []interface{}{
+		Record{
+			Url:        "https://test0.rss.com",
+			UpdateTime: time.Date(2023, 5, 23, 8, 52, 40, 0, time.UTC),
+			Redirect: Redirect{
+				Url:   "https://test0.rss.org",
+				Count: 3,
+			},
+		},
+		Record{
+			Url:        "https://test1.rss.com",
+			UpdateTime: time.Date(2023, 5, 23, 8, 52, 41, 0, time.UTC),
+		},
+		Record{
+			Url:        "https://test1.rss.org",
+			UpdateTime: time.Date(2023, 5, 23, 8, 52, 42, 0, time.UTC),
+		},
+	})
+	require.Nil(t, err)
+	//
+	cases := map[string]struct {
+		url    string
+		newUrl string
+		ut     time.Time
+	}{
+		"moved": {
+			url:    "https://test0.rss.com",
+			newUrl: "https://test0.rss.org",
+			ut:     time.Date(2023, 5, 23, 8, 52, 40, 0, time.UTC),
+		},
+		"new url record exists": {
+			url:    "https://test1.rss.com",
+			newUrl: "https://test1.rss.org",
+			ut:     time.Date(2023, 5, 23, 8, 52, 42, 0, time.UTC),
+		},
+	}
+	//
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			err = sm.Migrate(ctx, c.url, c.newUrl)
+			assert.Nil(t, err)
+			var rec Record
+			rec, err = sm.Get(ctx, c.newUrl)
+			assert.Equal(t, c.newUrl, rec.Url)
+			assert.Equal(t, c.ut, rec.UpdateTime)
+			assert.Equal(t, Redirect{}, rec.Redirect)
+			assert.Contains(t, rec.PrevUrls, c.url)
+			assert.Nil(t, err)
+			// the previous URL is resolved to the new one
+			rec, err = sm.Get(ctx, c.url)
+			assert.Equal(t, c.newUrl, rec.Url)
+			assert.Equal(t, c.ut, rec.UpdateTime)
+			assert.Nil(t, err)
+		})
+	}
+	//
+	var rec Record
+	rec, err = sm.Get(ctx, "https://missing.com")
+	assert.Equal(t, "https://missing.com", rec.Url)
+	assert.Nil(t, err)
+}
+
+func TestStorageMongo_SetStatus(t *testing.T) {
+	//
+	collName := fmt.Sprintf("feeds-test-%d", time.Now().UnixMicro())
+	dbCfg := config.DbConfig{
+		Uri:  dbUri,
+		Name: "producer-rss",
+	}
+	dbCfg.Table.Name = collName
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	s, err := NewStorage(ctx, connectTest(ctx, t, dbCfg), dbCfg)
+	require.NotNil(t, s)
+	require.Nil(t, err)
+	sm := s.(storageMongo)
+	defer clear(ctx, t, sm)
+	//
+	_, err = sm.coll.InsertOne(ctx, Record{
+		Url:        "https://test0.rss.com",
+		UpdateTime: time.Date(2023, 5, 23, 8, 52, 40, 0, time.UTC),
+		Status: Status{
+			State:     StateFailing,
+			Failures:  2,
+			LastError: "not found",
+		},
+	})
+	require.Nil(t, err)
+	//
+	cases := map[string]struct {
+		url string
+		s   Status
+	}{
+		"existing": {
+			url: "https://test0.rss.com",
+			s: Status{
+				State:     StateDisabled,
+				Failures:  3,
+				LastError: "not found",
+			},
+		},
+		"new": {
+			url: "https://test1.rss.com",
+			s: Status{
+				State:     StateGone,
+				Failures:  1,
+				LastError: "gone",
+			},
+		},
+	}
+	//
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			err = sm.SetStatus(ctx, c.url, c.s)
+			assert.Nil(t, err)
+			var rec Record
+			rec, err = sm.Get(ctx, c.url)
+			assert.Equal(t, c.s, rec.Status)
+			assert.Nil(t, err)
+		})
+	}
+	//
+	var rec Record
+	rec, err = sm.Get(ctx, "https://missing.com")
+	assert.Equal(t, Status{}, rec.Status)
+	assert.Nil(t, err)
+}
+
+func TestStorageMongo_ResetStatus(t *testing.T) {
+	//
+	collName := fmt.Sprintf("feeds-test-%d", time.Now().UnixMicro())
+	dbCfg := config.DbConfig{
+		Uri:  dbUri,
+		Name: "producer-rss",
+	}
+	dbCfg.Table.Name = collName
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	s, err := NewStorage(ctx, connectTest(ctx, t, dbCfg), dbCfg)
+	require.NotNil(t, s)
+	require.Nil(t, err)
+	sm := s.(storageMongo)
+	defer clear(ctx, t, sm)
+	//
+	_, err = sm.coll.InsertOne(ctx, Record{
+		Url:      "https://test0.rss.com",
+		PrevUrls: []string{"https://old.rss.com"},
+		Status: Status{
+			State:     StateGone,
+			Failures:  1,
+			LastError: "gone",
+		},
+	})
+	require.Nil(t, err)
+	//
+	cases := map[string]struct {
+		url string
+		err error
+	}{
+		"existing": {
+			url: "https://test0.rss.com",
+		},
+		"migrated": {
+			url: "https://old.rss.com",
+		},
+		"unknown": {
+			url: "https://test1.rss.com",
+			err: ErrUnknownFeed,
+		},
+	}
+	//
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			err = sm.ResetStatus(ctx, c.url)
+			assert.ErrorIs(t, err, c.err)
+			var rec Record
+			rec, err = sm.Get(ctx, c.url)
+			assert.Nil(t, err)
+			assert.Equal(t, Status{}, rec.Status)
+		})
+	}
+	// the unknown feed is not tracked
+	count, err := sm.coll.CountDocuments(ctx, bson.M{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), count)
+}
@@ -0,0 +1,33 @@
+package feeds
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Validators are the HTTP cache validators of the last fetched feed document.
+// These are used to make the conditional request and avoid downloading the unchanged feed again.
+type Validators struct {
+	ETag         string `bson:"etag,omitempty"`
+	LastModified string `bson:"lastmod,omitempty"`
+}
+
+// ErrNotModified means the feed document didn't change since the last fetch.
+var ErrNotModified = errors.New("not modified")
+
+// NewValidators extracts the validators from the response headers.
+func NewValidators(h http.Header) Validators {
+	return Validators{
+		ETag:         h.Get("ETag"),
+		LastModified: h.Get("Last-Modified"),
+	}
+}
+
+func (v Validators) apply(req *http.Request) {
+	if v.ETag != "" {
+		req.Header.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		req.Header.Set("If-Modified-Since", v.LastModified)
+	}
+}
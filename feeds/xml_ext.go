@@ -0,0 +1,457 @@
+package feeds
+
+import (
+	"bytes"
+	"encoding/xml"
+	"golang.org/x/exp/slices"
+	"golang.org/x/net/html/charset"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const nsXml = "http://www.w3.org/XML/1998/namespace"
+const nsAtom = "http://www.w3.org/2005/Atom"
+const nsFeedBurner = "http://rssnamespace.org/feedburner/ext/1.0"
+const nsMedia = "http://search.yahoo.com/mrss/"
+const nsYouTube = "http://www.youtube.com/xml/schemas/2015"
+const nsItunes = "http://www.itunes.com/dtds/podcast-1.0.dtd"
+const nsPodcast = "https://podcastindex.org/namespace/1.0"
+const nsDublinCore = "http://purl.org/dc/elements/1.1/"
+
+// The Atom content kinds by the way the content is kept by the rss package.
+const (
+	// atomContentNone is either no Atom content or the one kept as is, e.g. the out of line one
+	atomContentNone = iota
+	// atomContentEscaped is the text or HTML content kept escaped as in the document
+	atomContentEscaped
+	// atomContentXhtml is the XHTML content kept wrapped into the div element
+	atomContentXhtml
+)
+
+// xmlDoc contains the feed document data not supported by the rss package.
+type xmlDoc struct {
+	// base is the xml:base URL in effect for the feed, nil when none is declared
+	base *neturl.URL
+	// items are by the item id (RSS guid or link, Atom id)
+	items map[string]*xmlItem
+}
+
+type xmlItem struct {
+	// base is the xml:base URL in effect for the item, nil when none is declared
+	base *neturl.URL
+	ext  ItemExt
+	// text is the builder of the current element text, nil when the text is not needed
+	text                                          *strings.Builder
+	id, link, origLink, mediaDescription, videoId strings.Builder
+	thumbnailWidth                                int
+	// itunes are the iTunes element texts by the element name
+	itunes map[string]*strings.Builder
+	// authors are the author texts in the document order, rssAuthors are the RSS 2.0 author ones among these
+	authors    []*strings.Builder
+	rssAuthors map[*strings.Builder]bool
+	// atomAuthor is true inside the Atom author element
+	atomAuthor bool
+	// atomContent is the kind of the Atom content, atomContentNone if none, content is its decoded text
+	atomContent int
+	content     strings.Builder
+	// contentBase and summaryBase are the xml:base URLs in effect for the Atom content and summary, nil when none is
+	// declared
+	contentBase, summaryBase *neturl.URL
+}
+
+// applyXmlExt reads the feed document data not supported by the rss package: the relative feed and item link, image
+// and enclosure URLs are resolved against the xml:base declared, the item extensions are set including the xml:base in
+// effect for the content and summary. The relative xml:base is resolved against the enclosing one or the document URL. The Atom content is unescaped or unwrapped from the XHTML
+// div, since the rss package keeps it as in the document.
+func applyXmlExt(feed *Feed, data []byte, docUrl string) {
+	doc, err := neturl.Parse(docUrl)
+	if err != nil {
+		return
+	}
+	xd := scanXml(data, doc)
+	if xd.base != nil {
+		feed.Link = resolveRef(xd.base, feed.Link)
+		if feed.Image != nil {
+			feed.Image.URL = resolveRef(xd.base, feed.Image.URL)
+		}
+	}
+	for _, item := range feed.Items {
+		xi := xd.items[item.ID]
+		if xi == nil {
+			continue
+		}
+		switch xi.atomContent {
+		case atomContentEscaped:
+			item.Content = xi.content.String()
+		case atomContentXhtml:
+			item.Content = unwrapXhtmlDiv(item.Content)
+		}
+		xi.ext.ContentBase = xmlBase(xi.contentBase, xi.base)
+		xi.ext.SummaryBase = xmlBase(xi.summaryBase, xi.base)
+		if xi.base != nil {
+			item.Link = resolveRef(xi.base, item.Link)
+			if item.Image != nil {
+				item.Image.URL = resolveRef(xi.base, item.Image.URL)
+			}
+			for _, encl := range item.Enclosures {
+				encl.URL = resolveRef(xi.base, encl.URL)
+			}
+			xi.ext.OrigLink = resolveRef(xi.base, xi.ext.OrigLink)
+			xi.ext.Media.ThumbnailUrl = resolveRef(xi.base, xi.ext.Media.ThumbnailUrl)
+			xi.ext.Podcast.ImageUrl = resolveRef(xi.base, xi.ext.Podcast.ImageUrl)
+			xi.ext.Podcast.TranscriptUrl = resolveRef(xi.base, xi.ext.Podcast.TranscriptUrl)
+			xi.ext.Podcast.ChaptersUrl = resolveRef(xi.base, xi.ext.Podcast.ChaptersUrl)
+		}
+		feed.SetExt(item, xi.ext)
+	}
+}
+
+// scanXml reads the feed document, stops silently on the malformed one.
+func scanXml(data []byte, doc *neturl.URL) (xd xmlDoc) {
+	xd.items = map[string]*xmlItem{}
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.Strict = false
+	d.CharsetReader = charset.NewReaderLabel
+	// stack of the base URLs in effect, nil while no xml:base is declared
+	var stack []*neturl.URL
+	// item is the current item, itemDepth is its depth or zero when outside any item
+	var item *xmlItem
+	var itemDepth int
+	for {
+		t, err := d.Token()
+		if err != nil {
+			break
+		}
+		switch t := t.(type) {
+		case xml.StartElement:
+			var parent *neturl.URL
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1]
+			}
+			base := parent
+			for _, a := range t.Attr {
+				if a.Name.Local == "base" && (a.Name.Space == nsXml || a.Name.Space == "xml") {
+					ref := parent
+					if ref == nil {
+						ref = doc
+					}
+					if u, err := ref.Parse(strings.TrimSpace(a.Value)); err == nil {
+						base = u
+					}
+				}
+			}
+			stack = append(stack, base)
+			switch {
+			case itemDepth == 0 && (t.Name.Local == "item" || t.Name.Local == "entry"):
+				item = &xmlItem{
+					base: base,
+				}
+				itemDepth = len(stack)
+			case itemDepth == 0 && (t.Name.Local == "channel" || t.Name.Local == "feed"):
+				xd.base = base
+			case itemDepth > 0:
+				item.start(t, len(stack) == itemDepth+1, base)
+			}
+		case xml.CharData:
+			if item != nil && item.text != nil {
+				item.text.Write(t)
+			}
+		case xml.EndElement:
+			if itemDepth > 0 {
+				item.end()
+				if len(stack) == itemDepth {
+					item.finish()
+					id := item.id.String()
+					if id == "" {
+						id = item.link.String()
+					}
+					if _, found := xd.items[id]; id != "" && !found {
+						xd.items[id] = item
+					}
+					item = nil
+					itemDepth = 0
+				}
+			}
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	return
+}
+
+// start handles the start of the element inside the item, child is true for the item child element, base is the
+// xml:base URL in effect for the element.
+func (xi *xmlItem) start(t xml.StartElement, child bool, base *neturl.URL) {
+	xi.text = nil
+	if child {
+		xi.atomAuthor = t.Name.Space == nsAtom && t.Name.Local == "author"
+	}
+	switch t.Name.Space {
+	case "", nsAtom:
+		// RSS 2.0 and Atom elements
+		switch {
+		case child && t.Name.Space == nsAtom && t.Name.Local == "content":
+			xi.contentBase = base
+			xi.startAtomContent(t)
+		case child && t.Name.Space == nsAtom && t.Name.Local == "summary":
+			xi.summaryBase = base
+			xi.ext.SummaryType = atomTextType(xmlAttr(t, "type"))
+		case child && (t.Name.Local == "guid" || t.Name.Local == "id"):
+			xi.text = &xi.id
+		case child && t.Name.Local == "link":
+			xi.text = &xi.link
+		case child && t.Name.Space == "" && t.Name.Local == "author":
+			xi.text = xi.addAuthor()
+			if xi.rssAuthors == nil {
+				xi.rssAuthors = map[*strings.Builder]bool{}
+			}
+			xi.rssAuthors[xi.text] = true
+		case xi.atomAuthor && t.Name.Local == "name":
+			xi.text = xi.addAuthor()
+		}
+	case nsDublinCore:
+		if t.Name.Local == "creator" {
+			xi.text = xi.addAuthor()
+		}
+	case nsFeedBurner:
+		if t.Name.Local == "origLink" {
+			xi.text = &xi.origLink
+		}
+	case nsMedia:
+		xi.startMedia(t)
+	case nsYouTube:
+		if t.Name.Local == "videoId" {
+			xi.text = &xi.videoId
+		}
+	case nsItunes:
+		xi.startItunes(t)
+	case nsPodcast:
+		p := &xi.ext.Podcast
+		switch {
+		case t.Name.Local == "transcript" && p.TranscriptUrl == "":
+			p.TranscriptUrl = xmlAttr(t, "url")
+		case t.Name.Local == "chapters":
+			p.ChaptersUrl = xmlAttr(t, "url")
+		}
+	}
+}
+
+// startAtomContent sets the content type declared by the Atom content element, the text and HTML content text is
+// collected decoded.
+func (xi *xmlItem) startAtomContent(t xml.StartElement) {
+	if xmlAttr(t, "src") != "" {
+		// out of line content
+		return
+	}
+	switch typ := strings.ToLower(xmlAttr(t, "type")); {
+	case typ == "", typ == "text":
+		xi.ext.ContentType = contentTypeText
+		xi.atomContent = atomContentEscaped
+	case typ == "html":
+		xi.ext.ContentType = contentTypeHtml
+		xi.atomContent = atomContentEscaped
+	case typ == "xhtml":
+		xi.ext.ContentType = contentTypeHtml
+		xi.atomContent = atomContentXhtml
+	case strings.HasSuffix(typ, "xml"):
+		// the XML media type content is inline XML, kept as is
+		xi.ext.ContentType = typ
+	default:
+		xi.ext.ContentType = typ
+		xi.atomContent = atomContentEscaped
+	}
+	if xi.atomContent == atomContentEscaped {
+		xi.text = &xi.content
+	}
+}
+
+func (xi *xmlItem) startItunes(t xml.StartElement) {
+	switch t.Name.Local {
+	case "image":
+		xi.ext.Podcast.ImageUrl = xmlAttr(t, "href")
+	case "duration", "episode", "season", "explicit", "author":
+		if xi.itunes == nil {
+			xi.itunes = map[string]*strings.Builder{}
+		}
+		if xi.itunes[t.Name.Local] == nil {
+			xi.itunes[t.Name.Local] = &strings.Builder{}
+		}
+		xi.text = xi.itunes[t.Name.Local]
+	}
+}
+
+// startMedia handles the Media RSS elements either in the item or in the media:group.
+func (xi *xmlItem) startMedia(t xml.StartElement) {
+	m := &xi.ext.Media
+	switch t.Name.Local {
+	case "thumbnail":
+		// prefer the widest thumbnail
+		width, _ := strconv.Atoi(xmlAttr(t, "width"))
+		if url := xmlAttr(t, "url"); url != "" && (m.ThumbnailUrl == "" || width > xi.thumbnailWidth) {
+			m.ThumbnailUrl = url
+			xi.thumbnailWidth = width
+		}
+	case "content":
+		if seconds, err := strconv.ParseFloat(xmlAttr(t, "duration"), 64); err == nil && m.Duration == 0 {
+			m.Duration = time.Duration(seconds * float64(time.Second))
+		}
+	case "description":
+		xi.text = &xi.mediaDescription
+	case "starRating":
+		m.Rating = xmlAttr(t, "average")
+	case "statistics":
+		if views, err := strconv.ParseUint(xmlAttr(t, "views"), 10, 64); err == nil {
+			m.Views = views
+		}
+	}
+}
+
+func (xi *xmlItem) addAuthor() (sb *strings.Builder) {
+	sb = &strings.Builder{}
+	xi.authors = append(xi.authors, sb)
+	return
+}
+
+func (xi *xmlItem) end() {
+	xi.text = nil
+}
+
+// finish sets the item extensions collected from the element texts.
+func (xi *xmlItem) finish() {
+	xi.ext.OrigLink = strings.TrimSpace(xi.origLink.String())
+	xi.ext.Media.Description = strings.TrimSpace(xi.mediaDescription.String())
+	xi.ext.Media.VideoId = strings.TrimSpace(xi.videoId.String())
+	for _, sb := range xi.authors {
+		author := strings.TrimSpace(sb.String())
+		if xi.rssAuthors[sb] {
+			author = rssAuthorName(author)
+		}
+		if author != "" && !slices.Contains(xi.ext.Authors, author) {
+			xi.ext.Authors = append(xi.ext.Authors, author)
+		}
+	}
+	p := &xi.ext.Podcast
+	for name, sb := range xi.itunes {
+		v := strings.TrimSpace(sb.String())
+		switch name {
+		case "duration":
+			p.Duration = parseItunesDuration(v)
+		case "episode":
+			p.Episode, _ = strconv.Atoi(v)
+		case "season":
+			p.Season, _ = strconv.Atoi(v)
+		case "explicit":
+			p.Explicit = parseItunesExplicit(v)
+		case "author":
+			p.Author = v
+		}
+	}
+}
+
+// rssAuthorName returns the name from the RSS 2.0 author "email (name)", otherwise the author as is.
+func rssAuthorName(author string) (name string) {
+	name = author
+	if open := strings.Index(author, "("); open > 0 && strings.HasSuffix(author, ")") {
+		if n := strings.TrimSpace(author[open+1 : len(author)-1]); n != "" {
+			name = n
+		}
+	}
+	return
+}
+
+// parseItunesDuration parses the duration either in seconds or in the "[HH:]MM:SS" format, zero when invalid.
+func parseItunesDuration(s string) (d time.Duration) {
+	var seconds float64
+	for _, part := range strings.Split(s, ":") {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil || n < 0 {
+			return
+		}
+		seconds = seconds*60 + n
+	}
+	d = time.Duration(seconds * float64(time.Second))
+	return
+}
+
+func parseItunesExplicit(s string) (explicit *bool) {
+	var v bool
+	switch strings.ToLower(s) {
+	case "true", "yes", "explicit":
+		v = true
+		explicit = &v
+	case "false", "no", "clean":
+		explicit = &v
+	}
+	return
+}
+
+// atomTextType returns the type of the Atom text construct decoded by the rss package, empty when the XHTML one is not
+// decoded to the markup.
+func atomTextType(typ string) (t string) {
+	switch strings.ToLower(typ) {
+	case "", "text":
+		t = contentTypeText
+	case "html":
+		t = contentTypeHtml
+	}
+	return
+}
+
+// unwrapXhtmlDiv returns the contents of the Atom XHTML content div, the content as is when it's not wrapped.
+func unwrapXhtmlDiv(content string) string {
+	s := strings.TrimSpace(content)
+	end := strings.Index(s, ">")
+	if !strings.HasPrefix(s, "<") || end < 0 {
+		return content
+	}
+	tag := strings.Fields(strings.TrimSuffix(s[1:end], "/"))
+	if len(tag) == 0 {
+		return content
+	}
+	name := tag[0]
+	if _, local, _ := strings.Cut(name, ":"); name != "div" && local != "div" {
+		return content
+	}
+	if strings.HasSuffix(s[:end], "/") {
+		return ""
+	}
+	closing := "</" + name + ">"
+	if !strings.HasSuffix(s, closing) {
+		return content
+	}
+	return strings.TrimSpace(s[end+1 : len(s)-len(closing)])
+}
+
+func xmlAttr(t xml.StartElement, local string) (val string) {
+	for _, a := range t.Attr {
+		if a.Name.Space == "" && a.Name.Local == local {
+			val = strings.TrimSpace(a.Value)
+			break
+		}
+	}
+	return
+}
+
+// xmlBase returns the element xml:base URL or the item one if none, empty if neither.
+func xmlBase(base, itemBase *neturl.URL) (s string) {
+	if base == nil {
+		base = itemBase
+	}
+	if base != nil {
+		s = base.String()
+	}
+	return
+}
+
+func resolveRef(base *neturl.URL, ref string) (result string) {
+	result = ref
+	if ref != "" {
+		if u, err := base.Parse(strings.TrimSpace(ref)); err == nil {
+			result = u.String()
+		}
+	}
+	return
+}
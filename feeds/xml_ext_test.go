@@ -0,0 +1,454 @@
+package feeds
+
+import (
+	"github.com/SlyMarbo/rss"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestApplyXmlExt(t *testing.T) {
+	cases := map[string]struct {
+		doc       string
+		feedLink  string
+		links     []string
+		encls     []string
+		origLinks []string
+		// contentBases are the non-empty item content bases
+		contentBases []string
+	}{
+		"atom": {
+			doc: `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xml:base="https://blog.com/">
+  <title>Blog</title>
+  <link href="/" />
+  <id>urn:blog</id>
+  <updated>2023-05-01T00:00:00Z</updated>
+  <entry xml:base="posts/">
+    <title>Post 1</title>
+    <id>urn:post1</id>
+    <link href="1.html" />
+    <link rel="enclosure" type="audio/mpeg" href="1.mp3" />
+    <updated>2023-05-01T00:00:00Z</updated>
+  </entry>
+  <entry>
+    <title>Post 2</title>
+    <id>urn:post2</id>
+    <link href="https://other.com/2.html" />
+    <updated>2023-05-02T00:00:00Z</updated>
+    <content type="html" xml:base="media/">&lt;img src="2.png"&gt;</content>
+  </entry>
+</feed>`,
+			feedLink: "https://blog.com/",
+			links: []string{
+				"https://blog.com/posts/1.html",
+				"https://other.com/2.html",
+			},
+			encls: []string{
+				"https://blog.com/posts/1.mp3",
+			},
+			contentBases: []string{
+				"https://blog.com/posts/",
+				"https://blog.com/media/",
+			},
+		},
+		"rss": {
+			doc: `<?xml version="1.0" encoding="windows-1251"?>
+<rss version="2.0" xml:base="/news/">
+  <channel>
+    <title>News</title>
+    <link>index.html</link>
+    <description>News</description>
+    <item>
+      <title>Item 1</title>
+      <link>1.html</link>
+    </item>
+    <item xml:base="https://cdn.feed.com/">
+      <title>Item 2</title>
+      <guid isPermaLink="false">item2</guid>
+      <link>2.html</link>
+      <enclosure url="2.png" type="image/png" length="1"/>
+    </item>
+  </channel>
+</rss>`,
+			feedLink: "https://feed.com/news/index.html",
+			links: []string{
+				"https://feed.com/news/1.html",
+				"https://cdn.feed.com/2.html",
+			},
+			encls: []string{
+				"https://cdn.feed.com/2.png",
+			},
+			contentBases: []string{
+				"https://feed.com/news/",
+				"https://cdn.feed.com/",
+			},
+		},
+		"feedburner": {
+			doc: `<?xml version="1.0" encoding="UTF-8"?>
+<rss xmlns:feedburner="http://rssnamespace.org/feedburner/ext/1.0" version="2.0">
+  <channel>
+    <title>Blog</title>
+    <link>https://blog.com/</link>
+    <description>Blog</description>
+    <item>
+      <title>Post 1</title>
+      <link>https://feedproxy.google.com/~r/blog/~3/abc/</link>
+      <guid isPermaLink="false">post1</guid>
+      <feedburner:origLink> https://blog.com/posts/1 </feedburner:origLink>
+    </item>
+    <item>
+      <title>Post 2</title>
+      <link>https://blog.com/posts/2</link>
+    </item>
+  </channel>
+</rss>`,
+			feedLink: "https://blog.com/",
+			links: []string{
+				"https://feedproxy.google.com/~r/blog/~3/abc/",
+				"https://blog.com/posts/2",
+			},
+			origLinks: []string{
+				"https://blog.com/posts/1",
+			},
+		},
+		"no base": {
+			doc: `<?xml version="1.0"?>
+<rss version="2.0">
+  <channel>
+    <title>News</title>
+    <link>index.html</link>
+    <description>News</description>
+    <item>
+      <title>Item 1</title>
+      <link>1.html</link>
+    </item>
+  </channel>
+</rss>`,
+			feedLink: "index.html",
+			links: []string{
+				"1.html",
+			},
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			parsed, err := rss.Parse([]byte(c.doc))
+			assert.Nil(t, err)
+			feed := NewFeed(parsed)
+			applyXmlExt(feed, []byte(c.doc), "https://feed.com/rss")
+			assert.Equal(t, c.feedLink, feed.Link)
+			var links, encls, origLinks, contentBases []string
+			for _, item := range feed.Items {
+				links = append(links, item.Link)
+				for _, encl := range item.Enclosures {
+					encls = append(encls, encl.URL)
+				}
+				if origLink := feed.Ext(item).OrigLink; origLink != "" {
+					origLinks = append(origLinks, origLink)
+				}
+				if contentBase := feed.Ext(item).ContentBase; contentBase != "" {
+					contentBases = append(contentBases, contentBase)
+				}
+			}
+			assert.Equal(t, c.links, links)
+			assert.Equal(t, c.encls, encls)
+			assert.Equal(t, c.origLinks, origLinks)
+			assert.Equal(t, c.contentBases, contentBases)
+		})
+	}
+}
+
+func TestApplyXmlExt_AtomContent(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Blog</title>
+  <id>urn:blog</id>
+  <updated>2023-05-01T00:00:00Z</updated>
+  <entry>
+    <id>urn:html</id>
+    <summary type="html">&lt;b&gt;Hello&lt;/b&gt;</summary>
+    <content type="html">&lt;p&gt;Hello, &lt;b&gt;world&lt;/b&gt; &amp;amp; all&lt;/p&gt;</content>
+  </entry>
+  <entry>
+    <id>urn:cdata</id>
+    <content type="html"><![CDATA[<p>Hello, <b>world</b></p>]]></content>
+  </entry>
+  <entry>
+    <id>urn:text</id>
+    <summary>1 &lt; 2</summary>
+    <content>1 &lt; 2 &amp;&amp; 3 &gt; 2</content>
+  </entry>
+  <entry>
+    <id>urn:xhtml</id>
+    <content type="xhtml">
+      <div xmlns="http://www.w3.org/1999/xhtml"><p>Hello, <b>world</b></p></div>
+    </content>
+  </entry>
+  <entry>
+    <id>urn:src</id>
+    <content type="text/html" src="https://blog.com/1.html"/>
+  </entry>
+</feed>`
+	parsed, err := rss.Parse([]byte(doc))
+	require.Nil(t, err)
+	feed := NewFeed(parsed)
+	applyXmlExt(feed, []byte(doc), "https://blog.com/atom")
+	require.Equal(t, 5, len(feed.Items))
+	cases := []struct {
+		content     string
+		contentType string
+		summaryType string
+	}{
+		{
+			content:     "<p>Hello, <b>world</b> &amp; all</p>",
+			contentType: "text/html",
+			summaryType: "text/html",
+		},
+		{
+			content:     "<p>Hello, <b>world</b></p>",
+			contentType: "text/html",
+		},
+		{
+			content:     "1 < 2 && 3 > 2",
+			contentType: "text/plain",
+			summaryType: "text/plain",
+		},
+		{
+			content:     "<p>Hello, <b>world</b></p>",
+			contentType: "text/html",
+		},
+		{},
+	}
+	for i, c := range cases {
+		assert.Equal(t, c.content, feed.Items[i].Content, feed.Items[i].ID)
+		assert.Equal(t, c.contentType, feed.Ext(feed.Items[i]).ContentType, feed.Items[i].ID)
+		assert.Equal(t, c.summaryType, feed.Ext(feed.Items[i]).SummaryType, feed.Items[i].ID)
+	}
+}
+
+func TestUnwrapXhtmlDiv(t *testing.T) {
+	cases := map[string]struct {
+		in  string
+		out string
+	}{
+		"div": {
+			in:  `<div xmlns="http://www.w3.org/1999/xhtml"><p>Hi</p></div>`,
+			out: "<p>Hi</p>",
+		},
+		"prefixed div": {
+			in:  ` <xhtml:div xmlns:xhtml="http://www.w3.org/1999/xhtml">Hi</xhtml:div> `,
+			out: "Hi",
+		},
+		"empty div": {
+			in:  `<div xmlns="http://www.w3.org/1999/xhtml"/>`,
+			out: "",
+		},
+		"not wrapped": {
+			in:  "<p>Hi</p>",
+			out: "<p>Hi</p>",
+		},
+		"plain": {
+			in:  "plain",
+			out: "plain",
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			assert.Equal(t, c.out, unwrapXhtmlDiv(c.in))
+		})
+	}
+}
+
+func TestApplyXmlExt_Media(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns:yt="http://www.youtube.com/xml/schemas/2015" xmlns:media="http://search.yahoo.com/mrss/" xmlns="http://www.w3.org/2005/Atom">
+  <title>Channel</title>
+  <link rel="alternate" href="https://www.youtube.com/channel/UC1"/>
+  <id>yt:channel:UC1</id>
+  <entry>
+    <id>yt:video:abc</id>
+    <yt:videoId>abc</yt:videoId>
+    <yt:channelId>UC1</yt:channelId>
+    <title>Video</title>
+    <link rel="alternate" href="https://www.youtube.com/watch?v=abc"/>
+    <published>2023-05-01T00:00:00+00:00</published>
+    <media:group>
+      <media:title>Video</media:title>
+      <media:content url="https://www.youtube.com/v/abc?version=3" type="application/x-shockwave-flash" width="640" height="390" duration="123.5"/>
+      <media:thumbnail url="https://i1.ytimg.com/vi/abc/default.jpg" width="120" height="90"/>
+      <media:thumbnail url="https://i1.ytimg.com/vi/abc/hqdefault.jpg" width="480" height="360"/>
+      <media:description>Video description
+line 2</media:description>
+      <media:community>
+        <media:starRating count="1234" average="4.85" min="1" max="5"/>
+        <media:statistics views="3000000000"/>
+      </media:community>
+    </media:group>
+  </entry>
+  <entry>
+    <id>yt:video:def</id>
+    <title>Video 2</title>
+    <link rel="alternate" href="https://www.youtube.com/watch?v=def"/>
+    <published>2023-05-02T00:00:00+00:00</published>
+    <media:thumbnail url="/vi/def/default.jpg"/>
+  </entry>
+</feed>`
+	parsed, err := rss.Parse([]byte(doc))
+	require.Nil(t, err)
+	feed := NewFeed(parsed)
+	applyXmlExt(feed, []byte(doc), "https://www.youtube.com/feeds/videos.xml?channel_id=UC1")
+	require.Equal(t, 2, len(feed.Items))
+	assert.Equal(t, ItemMedia{
+		ThumbnailUrl: "https://i1.ytimg.com/vi/abc/hqdefault.jpg",
+		Description:  "Video description\nline 2",
+		Duration:     123500 * time.Millisecond,
+		Views:        3_000_000_000,
+		Rating:       "4.85",
+		VideoId:      "abc",
+	}, feed.Ext(feed.Items[0]).Media)
+	assert.Equal(t, ItemMedia{
+		ThumbnailUrl: "/vi/def/default.jpg",
+	}, feed.Ext(feed.Items[1]).Media)
+}
+
+func TestApplyXmlExt_Podcast(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd" xmlns:podcast="https://podcastindex.org/namespace/1.0">
+  <channel>
+    <title>Podcast</title>
+    <link>https://podcast.com/</link>
+    <description>Podcast</description>
+    <itunes:author>Channel Author</itunes:author>
+    <item>
+      <title>Episode 2</title>
+      <guid>ep2</guid>
+      <enclosure url="https://podcast.com/ep2.mp3" type="audio/mpeg" length="12345"/>
+      <itunes:duration>1:02:03</itunes:duration>
+      <itunes:episode>2</itunes:episode>
+      <itunes:season>1</itunes:season>
+      <itunes:explicit>yes</itunes:explicit>
+      <itunes:author>John Doe</itunes:author>
+      <itunes:image href="/ep2.jpg"/>
+      <podcast:transcript url="https://podcast.com/ep2.vtt" type="text/vtt"/>
+      <podcast:transcript url="https://podcast.com/ep2.srt" type="application/srt"/>
+      <podcast:chapters url="https://podcast.com/ep2.json" type="application/json+chapters"/>
+    </item>
+    <item>
+      <title>Episode 1</title>
+      <guid>ep1</guid>
+      <itunes:duration>95</itunes:duration>
+      <itunes:explicit>clean</itunes:explicit>
+    </item>
+  </channel>
+</rss>`
+	parsed, err := rss.Parse([]byte(doc))
+	require.Nil(t, err)
+	feed := NewFeed(parsed)
+	applyXmlExt(feed, []byte(doc), "https://podcast.com/rss")
+	require.Equal(t, 2, len(feed.Items))
+	explicit := true
+	assert.Equal(t, ItemPodcast{
+		Duration:      time.Hour + 2*time.Minute + 3*time.Second,
+		Episode:       2,
+		Season:        1,
+		Explicit:      &explicit,
+		Author:        "John Doe",
+		ImageUrl:      "/ep2.jpg",
+		TranscriptUrl: "https://podcast.com/ep2.vtt",
+		ChaptersUrl:   "https://podcast.com/ep2.json",
+	}, feed.Ext(feed.Items[0]).Podcast)
+	clean := false
+	assert.Equal(t, ItemPodcast{
+		Duration: 95 * time.Second,
+		Explicit: &clean,
+	}, feed.Ext(feed.Items[1]).Podcast)
+}
+
+func TestParseItunesDuration(t *testing.T) {
+	cases := map[string]time.Duration{
+		"":        0,
+		"95":      95 * time.Second,
+		"12.5":    12500 * time.Millisecond,
+		"01:35":   95 * time.Second,
+		"1:02:03": time.Hour + 2*time.Minute + 3*time.Second,
+		"1:xx":    0,
+		"-5":      0,
+	}
+	for in, out := range cases {
+		assert.Equal(t, out, parseItunesDuration(in), in)
+	}
+}
+
+func TestApplyXmlExt_Authors(t *testing.T) {
+	cases := map[string]struct {
+		doc     string
+		authors [][]string
+	}{
+		"rss": {
+			doc: `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/">
+  <channel>
+    <title>News</title>
+    <link>https://news.com/</link>
+    <description>News</description>
+    <item>
+      <title>Item 1</title>
+      <guid>item1</guid>
+      <author>jd@news.com (John Doe)</author>
+      <dc:creator>Jane Roe</dc:creator>
+      <dc:creator>John Doe</dc:creator>
+    </item>
+    <item>
+      <title>Item 2</title>
+      <guid>item2</guid>
+      <author>jd@news.com</author>
+    </item>
+    <item>
+      <title>Item 3</title>
+      <guid>item3</guid>
+    </item>
+  </channel>
+</rss>`,
+			authors: [][]string{
+				{"John Doe", "Jane Roe"},
+				{"jd@news.com"},
+				nil,
+			},
+		},
+		"atom": {
+			doc: `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Blog</title>
+  <id>urn:blog</id>
+  <author><name>Blog Team</name></author>
+  <updated>2023-05-01T00:00:00Z</updated>
+  <entry>
+    <title>Post 1</title>
+    <id>urn:post1</id>
+    <updated>2023-05-01T00:00:00Z</updated>
+    <author><name>John Doe</name><email>jd@blog.com</email></author>
+    <author><name> Jane Roe </name></author>
+    <contributor><name>Editor</name></contributor>
+  </entry>
+</feed>`,
+			authors: [][]string{
+				{"John Doe", "Jane Roe"},
+			},
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			parsed, err := rss.Parse([]byte(c.doc))
+			require.Nil(t, err)
+			feed := NewFeed(parsed)
+			applyXmlExt(feed, []byte(c.doc), "https://feed.com/rss")
+			var authors [][]string
+			for _, item := range feed.Items {
+				authors = append(authors, feed.Ext(item).Authors)
+			}
+			assert.Equal(t, c.authors, authors)
+		})
+	}
+}
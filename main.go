@@ -3,33 +3,94 @@ package main
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
-	"github.com/SlyMarbo/rss"
 	"github.com/awakari/client-sdk-go/api"
+	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/exp/slog"
 	"google.golang.org/grpc/metadata"
 	"net/http"
 	"os"
+	"os/signal"
 	"producer-rss/config"
 	"producer-rss/converter"
 	"producer-rss/feeds"
 	"producer-rss/producer"
+	"producer-rss/scheduler"
+	"producer-rss/updater"
+	"sync"
+	"syscall"
 	"time"
 )
 
+// cmdEnable is the command to re-enable the retired feeds, followed by the feed URLs.
+const cmdEnable = "enable"
+
 func main() {
+	os.Exit(run())
+}
+
+// run returns the process exit code, see updater.ExitCode. The unrecoverable initialization failures cause panics.
+func run() (code int) {
 	//
 	cfg, err := config.NewConfigFromEnv()
 	if err != nil {
 		panic(fmt.Sprintf("failed to load the config from env: %s", err))
 	}
-	ctx := context.TODO()
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
 	//
 	opts := slog.HandlerOptions{
 		Level: slog.Level(cfg.Log.Level),
 	}
 	log := slog.New(opts.NewTextHandler(os.Stdout))
-	log.Info(fmt.Sprintf("starting the update for the feed @ %s", cfg.Feed.Url))
+	//
+	var db *mongo.Database
+	db, err = feeds.Connect(ctx, cfg.Db)
+	if err != nil {
+		panic(fmt.Sprintf("failed to connect the DB: %s", err))
+	}
+	defer db.Client().Disconnect(context.TODO())
+	var stor feeds.Storage
+	stor, err = feeds.NewStorage(ctx, db, cfg.Db)
+	if err != nil {
+		panic(fmt.Sprintf("failed to initialize the storage: %s", err))
+	}
+	var seen feeds.SeenItems
+	seen, err = feeds.NewSeenItems(ctx, db, cfg.Db)
+	if err != nil {
+		panic(fmt.Sprintf("failed to initialize the seen items storage: %s", err))
+	}
+	var outbox feeds.Outbox
+	outbox, err = feeds.NewOutbox(ctx, db, cfg.Db)
+	if err != nil {
+		panic(fmt.Sprintf("failed to initialize the outbox: %s", err))
+	}
+	//
+	if len(os.Args) > 1 && os.Args[1] == cmdEnable {
+		err = enableFeeds(ctx, stor, os.Args[2:], log)
+		return updater.ExitCode(err)
+	}
+	//
+	var feedUrls []string
+	switch {
+	case cfg.Feed.Url != "":
+		// single feed mode, the feed URLs list file is ignored
+		feedUrls = []string{
+			cfg.Feed.Url,
+		}
+	case len(os.Args) > 1:
+		var fl config.FeedList
+		fl, err = config.NewFeedUrlsFromFile(os.Args[1])
+		if err != nil {
+			panic(fmt.Sprintf("failed to load the feed URLs list from the file %s: %s", os.Args[1], err))
+		}
+		feedUrls = fl.Urls
+		cfg.Message.Content.ModeByFeed = fl.ContentModes
+		cfg.Message.KeptParamsByFeed = fl.KeptParams
+	default:
+		panic("neither FEED_URL is set nor the feed URLs list file is specified")
+	}
 	//
 	httpClient := http.Client{
 		Timeout: cfg.Feed.UpdateTimeout,
@@ -40,23 +101,11 @@ func main() {
 		},
 	}
 	feedsClient := feeds.NewClient(httpClient, cfg.Feed.UserAgent)
+	feedsClient = feeds.NewLimiterMiddleware(feedsClient, cfg.Feed.HostLimit)
 	feedsClient = feeds.NewLoggingMiddleware(feedsClient, log)
+	feedsClient = feeds.NewRetryMiddleware(feedsClient, cfg.Feed.Retry)
 	log.Info("initialized the RSS client")
 	//
-	var stor feeds.Storage
-	stor, err = feeds.NewStorage(ctx, cfg.Db)
-	if err != nil {
-		panic(fmt.Sprintf("failed to initialize the storage: %s", err))
-	}
-	defer stor.Close()
-	//
-	var feedUpdTime time.Time
-	feedUpdTime, err = stor.GetUpdateTime(ctx, cfg.Feed.Url)
-	if err != nil {
-		panic(fmt.Sprintf("failed to read the feed update time: %s", err))
-	}
-	log.Info(fmt.Sprintf("feed %s: update time is %s", cfg.Feed.Url, feedUpdTime.Format(time.RFC3339)))
-	//
 	var awakariClient api.Client
 	awakariClient, err = api.
 		NewClientBuilder().
@@ -80,31 +129,150 @@ func main() {
 	defer ws.Close()
 	log.Info("opened the messages writer")
 	//
-	var feed *rss.Feed
-	feed, err = rss.FetchByFunc(feedsClient.Get, cfg.Feed.Url)
-	if err != nil {
-		log.Error(fmt.Sprintf("failed to fetch the feed: %s:", err))
-	}
-	log.Info(fmt.Sprintf("feed contains %d items to process", len(feed.Items)))
-	//
-	conv := converter.NewConverter(cfg.Message)
+	conv := converter.NewConverter(cfg.Message, log)
 	conv = converter.NewConverterLogging(conv, log)
-	prod := producer.NewProducer(feed, feedUpdTime, conv, ws, cfg.Api.Writer.Backoff, cfg.Api.Writer.BatchSize)
-	prod = producer.NewProducerLogging(prod, log)
+	// the feeds are updated concurrently, the writes to the single messages stream should not interleave
+	output := producer.NewWriterSync(ws)
+	upd := updater.NewUpdater(
+		feedsClient,
+		stor,
+		seen,
+		outbox,
+		conv,
+		output,
+		cfg.Api.Writer,
+		cfg.Feed,
+		log,
+	)
+	upd = updater.NewUpdaterLogging(upd, log)
 	//
-	var newFeedUpdTime time.Time
-	if err == nil {
-		newFeedUpdTime, err = prod.Produce(ctx)
+	var errRun error
+	resender := producer.NewResender(outbox, seen, output, cfg.Api.Writer)
+	resentCount, err := resender.Resend(ctx)
+	switch {
+	case errors.Is(err, feeds.ErrInternal):
+		errRun = fmt.Errorf("%w: %w", updater.ErrStorage, err)
+	case err != nil:
+		errRun = fmt.Errorf("%w: %w", updater.ErrWrite, err)
 	}
-	if err != nil {
-		log.Error(fmt.Sprintf("failed to process the feed: %s", err))
+	if errRun == nil {
+		log.Info(fmt.Sprintf("resent %d messages left in the outbox by the previous run", resentCount))
+	} else {
+		log.Error(fmt.Sprintf("failed to resend the messages left in the outbox by the previous run, resent %d: %s", resentCount, errRun))
 	}
-	if !newFeedUpdTime.After(feedUpdTime) {
-		newFeedUpdTime = time.Now().UTC()
+	// the feeds are updated anyway, the messages failing to resend are moved to the dead letters eventually
+	//
+	if cfg.Daemon {
+		log.Info(fmt.Sprintf("starting the scheduled updates for %d feeds", len(feedUrls)))
+		resender = producer.NewResenderLogging(resender, log)
+		sched := scheduler.NewScheduler(upd, resender, feedUrls, cfg.Feed.UpdateIntervalMin, cfg.Feed.UpdateIntervalMax, cfg.Feed.UpdateConcurrency)
+		err = sched.Run(ctx)
+		if ctx.Err() != nil {
+			// stopped by the signal, not a failure
+			log.Info(fmt.Sprintf("stopped the scheduled updates: %s", err))
+			return updater.ExitCodeOk
+		}
+		// the writer stream is not reopened, so exit to be restarted with the new one
+		code = updater.ExitCode(err)
+		log.Error(fmt.Sprintf("stopped the scheduled updates: %s, exit code %d", err, code))
+		return
 	}
-	log.Info(fmt.Sprintf("setting the new update time to %s", newFeedUpdTime.Format(time.RFC3339)))
-	err = stor.SetUpdateTime(ctx, cfg.Feed.Url, newFeedUpdTime)
-	if err != nil {
-		log.Error(fmt.Sprintf("failed to set the new update time for the feed: %s:", err))
+	log.Info(fmt.Sprintf("starting the update for %d feeds", len(feedUrls)))
+	reports, failCount, err := updateAll(ctx, upd, feedUrls, cfg.Feed.UpdateConcurrency, log)
+	errRun = errors.Join(errRun, err)
+	log.Info(fmt.Sprintf("finished the update for %d feeds, %d failed", len(feedUrls), failCount))
+	for _, r := range reports {
+		switch {
+		case r.Skipped:
+			log.Warn(fmt.Sprintf("feed %s: skipped, %s since %d consecutive failures, last error: %s", r.Url, r.Status.State, r.Status.Failures, r.Status.LastError))
+		case r.NotModified:
+			log.Info(fmt.Sprintf("feed %s: not modified, update time is %s", r.Url, r.UpdateTime.Format(time.RFC3339)))
+		default:
+			log.Info(fmt.Sprintf("feed %s: %d items, update time is %s", r.Url, r.ItemCount, r.UpdateTime.Format(time.RFC3339)))
+		}
+		if r.MovedTo != "" {
+			log.Warn(fmt.Sprintf("feed %s: moved permanently to %s", r.Url, r.MovedTo))
+		}
+	}
+	code = updater.ExitCode(errRun)
+	if code != updater.ExitCodeOk {
+		log.Error(fmt.Sprintf("finished with the exit code %d", code))
+	}
+	return
+}
+
+// updateAll updates the feeds running up to the concurrency updates at the same time, at least one. No more updates
+// are started once the run is interrupted or the writer retries are exhausted. The reports are in the feeds order,
+// the feeds not updated are omitted.
+func updateAll(ctx context.Context, upd updater.Updater, urls []string, concurrency uint32, log *slog.Logger) (reports []updater.Report, failCount int, err error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	results := make([]*updater.Report, len(urls))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var writerDown bool
+	for i, url := range urls {
+		sem <- struct{}{}
+		lock.Lock()
+		stop := writerDown
+		lock.Unlock()
+		if stop {
+			// the writer is down, no sense to fetch the remaining feeds
+			log.Error("stopped the update, the writer retries are exhausted")
+			break
+		}
+		if ctx.Err() != nil {
+			log.Warn(fmt.Sprintf("interrupted the update: %s", ctx.Err()))
+			break
+		}
+		wg.Add(1)
+		go func(i int, url string) {
+			defer wg.Done()
+			defer func() {
+				<-sem
+			}()
+			r, errUpd := upd.Update(ctx, url)
+			if errUpd != nil {
+				log.Error(fmt.Sprintf("feed %s: failed to update: %s", r.Url, errUpd))
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			results[i] = &r
+			if errUpd != nil {
+				failCount++
+				err = errors.Join(err, errUpd)
+			}
+			if errors.Is(errUpd, producer.ErrWriteRetriesExhausted) {
+				writerDown = true
+			}
+		}(i, url)
+	}
+	wg.Wait()
+	for _, r := range results {
+		if r != nil {
+			reports = append(reports, *r)
+		}
+	}
+	return
+}
+
+// enableFeeds clears the retired status of the known feeds. The unknown feed URL is reported as the error, e.g. a typo,
+// and not tracked.
+func enableFeeds(ctx context.Context, stor feeds.Storage, urls []string, log *slog.Logger) (err error) {
+	for _, url := range urls {
+		errEnable := stor.ResetStatus(ctx, url)
+		switch {
+		case errEnable == nil:
+			log.Info(fmt.Sprintf("feed %s: enabled", url))
+		case errors.Is(errEnable, feeds.ErrInternal):
+			log.Error(fmt.Sprintf("feed %s: failed to enable: %s", url, errEnable))
+			err = errors.Join(err, fmt.Errorf("%w: %w", updater.ErrStorage, errEnable))
+		default:
+			log.Error(fmt.Sprintf("feed %s: failed to enable: %s", url, errEnable))
+			err = errors.Join(err, errEnable)
+		}
 	}
+	return
 }
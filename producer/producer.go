@@ -2,11 +2,13 @@ package producer
 
 import (
 	"context"
-	"errors"
 	"github.com/SlyMarbo/rss"
 	"github.com/awakari/client-sdk-go/model"
 	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"producer-rss/config"
 	"producer-rss/converter"
+	"producer-rss/feeds"
+	"sort"
 	"time"
 )
 
@@ -15,73 +17,133 @@ type Producer interface {
 }
 
 type producer struct {
-	feed            *rss.Feed
-	timeMin         time.Time
-	conv            converter.Converter
-	output          model.Writer[*pb.CloudEvent]
-	outputBackoff   time.Duration
-	outputBatchSize uint32
+	feed      *feeds.Feed
+	timeMin   time.Time
+	seen      feeds.SeenItems
+	outbox    feeds.Outbox
+	conv      converter.Converter
+	batchSize uint32
+	sender    sender
 }
 
-func NewProducer(feed *rss.Feed, timeMin time.Time, conv converter.Converter, output model.Writer[*pb.CloudEvent], outputBackoff time.Duration, outputBatchSize uint32) Producer {
+// NewProducer returns the producer of the messages for the new feed items. An item is new when its id (or link) is not
+// seen before and it's not older than the time min. The items having no id nor link are new when they are newer than
+// the time min. The time min doesn't filter out the undated items.
+// Every messages batch is persisted to the outbox before writing to the output, so the messages left unsent by the
+// crash are sent by the Resender next time.
+func NewProducer(feed *feeds.Feed, timeMin time.Time, seen feeds.SeenItems, outbox feeds.Outbox, conv converter.Converter, output model.Writer[*pb.CloudEvent], cfgOutput config.WriterConfig) Producer {
 	return producer{
-		feed:            feed,
-		timeMin:         timeMin,
-		conv:            conv,
-		output:          output,
-		outputBackoff:   outputBackoff,
-		outputBatchSize: outputBatchSize,
+		feed:      feed,
+		timeMin:   timeMin,
+		seen:      seen,
+		outbox:    outbox,
+		conv:      conv,
+		batchSize: cfgOutput.BatchSize,
+		sender:    newSender(outbox, seen, output, cfgOutput),
 	}
 }
 
 func (p producer) Produce(ctx context.Context) (timeMax time.Time, err error) {
 	timeMax = p.timeMin
-	var msgBatch []*pb.CloudEvent
-	var msg *pb.CloudEvent
-	for _, item := range p.feed.Items {
-		if item.Date.IsZero() || item.Date.After(p.timeMin) {
-			msg = p.conv.Convert(p.feed, item)
-			msgBatch = append(msgBatch, msg)
-			if uint32(len(msgBatch)) == p.outputBatchSize {
+	url := p.feed.UpdateURL
+	items := sortByDate(p.feed.Items)
+	var ids []string
+	for _, item := range items {
+		if id := feeds.ItemId(item); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	var seen map[string]bool
+	seen, err = p.seen.GetSeen(ctx, url, ids)
+	if err != nil {
+		return
+	}
+	// count of the leading sorted items that are either sent or skipped
+	doneCount := len(items)
+	var batch []feeds.OutboxEntry
+	var posBatch []int
+	var ackCount uint32
+	for i, item := range items {
+		id := feeds.ItemId(item)
+		if p.isNew(item, id, seen) {
+			if id != "" {
+				// skip the duplicate items in the same feed
+				seen[id] = true
+			}
+			batch = append(batch, feeds.OutboxEntry{
+				Url:    url,
+				ItemId: id,
+				Msg:    p.conv.Convert(p.feed, item),
+			})
+			posBatch = append(posBatch, i)
+			if uint32(len(batch)) == p.batchSize {
 				// flush
-				err = errors.Join(err, p.sendMessages(ctx, msgBatch))
-				msgBatch = []*pb.CloudEvent{}
+				ackCount, err = p.flush(ctx, batch)
+				if err != nil {
+					doneCount = unsentPos(posBatch, ackCount)
+					break
+				}
+				batch = []feeds.OutboxEntry{}
+				posBatch = []int{}
 			}
 		}
+	}
+	// send the remaining messages, if any
+	if err == nil && len(batch) > 0 {
+		ackCount, err = p.flush(ctx, batch)
+		if err != nil {
+			doneCount = unsentPos(posBatch, ackCount)
+		}
+	}
+	for _, item := range items[:doneCount] {
 		if item.Date.After(timeMax) {
 			timeMax = item.Date
 		}
 	}
-	// send the remaining messages, if any
-	if len(msgBatch) > 0 {
-		err = errors.Join(err, p.sendMessages(ctx, msgBatch))
-	}
-	if timeMax.IsZero() {
+	if err == nil && timeMax.IsZero() {
 		timeMax = time.Now().UTC()
 	}
 	return
 }
 
-func (p producer) sendMessages(ctx context.Context, msgs []*pb.CloudEvent) (err error) {
-	msgCount := uint32(len(msgs))
-	var ackCount uint32
-	var n uint32
-	for ackCount < msgCount {
-		n, err = p.output.WriteBatch(msgs[ackCount:])
-		ackCount += n
-		if err != nil {
-			break
-		}
-		if n == 0 {
-			time.Sleep(p.outputBackoff)
-		}
-		select {
-		case <-ctx.Done():
-			err = ctx.Err()
-			break
-		default:
-			continue
-		}
+func (p producer) isNew(item *rss.Item, id string, seen map[string]bool) (ok bool) {
+	switch {
+	case id == "":
+		ok = item.Date.IsZero() || item.Date.After(p.timeMin)
+	case seen[id]:
+		ok = false
+	default:
+		// the item published at the same time as the previous newest item is not lost, it's not seen yet
+		ok = item.Date.IsZero() || !item.Date.Before(p.timeMin)
+	}
+	return
+}
+
+func (p producer) flush(ctx context.Context, batch []feeds.OutboxEntry) (ackCount uint32, err error) {
+	err = p.outbox.Put(ctx, batch)
+	if err == nil {
+		ackCount, err = p.sender.send(ctx, batch)
 	}
 	return
 }
+
+// unsentPos returns the position of the first unsent item in the sorted items.
+func unsentPos(posBatch []int, ackCount uint32) (pos int) {
+	if int(ackCount) < len(posBatch) {
+		pos = posBatch[ackCount]
+	} else {
+		// all sent but failed to mark as seen
+		pos = posBatch[len(posBatch)-1] + 1
+	}
+	return
+}
+
+// sortByDate returns the copy of the items sorted oldest first, the undated items go first keeping their order.
+func sortByDate(items []*rss.Item) (sorted []*rss.Item) {
+	sorted = make([]*rss.Item, len(items))
+	copy(sorted, items)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Date.Before(sorted[j].Date)
+	})
+	return
+}
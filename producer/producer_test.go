@@ -2,6 +2,8 @@ package producer
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/SlyMarbo/rss"
 	"github.com/awakari/client-sdk-go/model"
 	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
@@ -11,10 +13,19 @@ import (
 	"os"
 	"producer-rss/config"
 	"producer-rss/converter"
+	"producer-rss/feeds"
 	"testing"
 	"time"
 )
 
+var cfgOutput = config.WriterConfig{
+	AttemptsMax: 3,
+	Backoff:     time.Millisecond,
+	BackoffMax:  10 * time.Millisecond,
+	BatchSize:   2,
+	Timeout:     time.Second,
+}
+
 func TestProducer_Produce(t *testing.T) {
 	feed := &rss.Feed{
 		Nickname:    "test-feed-name-0",
@@ -49,11 +60,11 @@ func TestProducer_Produce(t *testing.T) {
 	os.Setenv("FEED_URL", "https://test-feed-0.nz")
 	cfg, err := config.NewConfigFromEnv()
 	require.Nil(t, err)
-	conv := converter.NewConverter(cfg.Message)
+	conv := converter.NewConverter(cfg.Message, slog.Default())
 	conv = converter.NewConverterLogging(conv, slog.Default())
 	out := &testOutput{}
 	timeMin := time.Date(2023, 6, 9, 7, 32, 0, 0, time.UTC)
-	p := NewProducer(feed, timeMin, conv, out, 1*time.Second, 2)
+	p := NewProducer(feeds.NewFeed(feed), timeMin, feeds.NewSeenItemsMock(), feeds.NewOutboxMock(), conv, out, cfgOutput)
 	p = NewProducerLogging(p, slog.Default())
 	var timeNext time.Time
 	timeNext, err = p.Produce(context.TODO())
@@ -64,8 +75,197 @@ func TestProducer_Produce(t *testing.T) {
 	assert.Equal(t, "https://test-feed-0.nz/item1", out.Msgs[0].Attributes["subject"].GetCeString())
 }
 
+func TestProducer_Produce_Seen(t *testing.T) {
+	timeMin := time.Date(2023, 6, 9, 7, 32, 0, 0, time.UTC)
+	feed := &rss.Feed{
+		UpdateURL: "https://test-feed-0.nz",
+		Items: []*rss.Item{
+			{
+				Title: "undated",
+				ID:    "item0",
+			},
+			{
+				Title: "same time as the previous newest item",
+				Link:  "https://test-feed-0.nz/item1",
+				Date:  timeMin,
+			},
+			{
+				Title: "older than the previous newest item",
+				ID:    "item2",
+				Date:  timeMin.Add(-time.Minute),
+			},
+			{
+				Title: "no id, newer",
+				Date:  timeMin.Add(time.Minute),
+			},
+			{
+				Title: "no id, same time as the previous newest item",
+				Date:  timeMin,
+			},
+			{
+				Title: "duplicate",
+				ID:    "item0",
+			},
+		},
+	}
+	conv := converter.NewConverter(config.MessageConfig{}, slog.Default())
+	seen := feeds.NewSeenItemsMock()
+	out := &testOutput{}
+	p := NewProducer(feeds.NewFeed(feed), timeMin, seen, feeds.NewOutboxMock(), conv, out, cfgOutput)
+	timeNext, err := p.Produce(context.TODO())
+	require.Nil(t, err)
+	assert.Equal(t, timeMin.Add(time.Minute), timeNext)
+	assert.Equal(t, 3, len(out.Msgs))
+	// the seen items are not produced again
+	out.Msgs = nil
+	p = NewProducer(feeds.NewFeed(feed), timeNext, seen, feeds.NewOutboxMock(), conv, out, cfgOutput)
+	_, err = p.Produce(context.TODO())
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(out.Msgs))
+	// seen items store failure
+	feed.UpdateURL = "storage-fail"
+	p = NewProducer(feeds.NewFeed(feed), timeNext, seen, feeds.NewOutboxMock(), conv, out, cfgOutput)
+	_, err = p.Produce(context.TODO())
+	assert.ErrorIs(t, err, feeds.ErrInternal)
+}
+
+func TestProducer_Produce_PartialFailure(t *testing.T) {
+	timeMin := time.Date(2023, 6, 9, 7, 30, 0, 0, time.UTC)
+	feed := &rss.Feed{
+		UpdateURL: "https://test-feed-0.nz",
+	}
+	// newest first, as usual
+	for i := 5; i > 0; i-- {
+		feed.Items = append(feed.Items, &rss.Item{
+			Title: fmt.Sprintf("item-%d", i),
+			ID:    fmt.Sprintf("item%d", i),
+			Date:  timeMin.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	var cfgMsg config.MessageConfig
+	cfgMsg.Metadata.KeyTitle = "title"
+	conv := converter.NewConverter(cfgMsg, slog.Default())
+	out := &testOutput{
+		AckMax: 3,
+	}
+	p := NewProducer(feeds.NewFeed(feed), timeMin, feeds.NewSeenItemsMock(), feeds.NewOutboxMock(), conv, out, cfgOutput)
+	timeNext, err := p.Produce(context.TODO())
+	assert.ErrorIs(t, err, ErrWriteRetriesExhausted)
+	// the checkpoint doesn't pass the unsent items
+	assert.Equal(t, timeMin.Add(3*time.Minute), timeNext)
+	require.Equal(t, 3, len(out.Msgs))
+	for i, msg := range out.Msgs {
+		assert.Equal(t, fmt.Sprintf("item-%d", i+1), msg.Attributes["title"].GetCeString())
+	}
+}
+
+func TestProducer_Produce_Retry(t *testing.T) {
+	feed := &rss.Feed{
+		UpdateURL: "https://test-feed-0.nz",
+		Items: []*rss.Item{
+			{
+				ID: "item0",
+			},
+			{
+				ID: "item1",
+			},
+		},
+	}
+	conv := converter.NewConverter(config.MessageConfig{}, slog.Default())
+	cases := map[string]struct {
+		out     *rejectingOutput
+		cfg     config.WriterConfig
+		timeout time.Duration
+		count   int
+		err     error
+	}{
+		"accepted after rejects": {
+			out: &rejectingOutput{
+				rejectCount: 2,
+			},
+			cfg:   cfgOutput,
+			count: 2,
+		},
+		"attempts exhausted": {
+			out: &rejectingOutput{
+				rejectCount: 3,
+			},
+			cfg: cfgOutput,
+			err: ErrWriteRetriesExhausted,
+		},
+		"time exhausted": {
+			out: &rejectingOutput{
+				rejectCount: 2,
+			},
+			cfg: config.WriterConfig{
+				AttemptsMax: 100,
+				Backoff:     time.Second,
+				BackoffMax:  time.Second,
+				BatchSize:   2,
+				Timeout:     100 * time.Millisecond,
+			},
+			err: ErrWriteRetriesExhausted,
+		},
+		"cancelled": {
+			out: &rejectingOutput{
+				rejectCount: 2,
+			},
+			cfg: config.WriterConfig{
+				AttemptsMax: 100,
+				Backoff:     time.Minute,
+				BackoffMax:  time.Minute,
+				BatchSize:   2,
+				Timeout:     time.Hour,
+			},
+			timeout: 100 * time.Millisecond,
+			err:     context.DeadlineExceeded,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			ctx := context.Background()
+			if c.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, c.timeout)
+				defer cancel()
+			}
+			p := NewProducer(feeds.NewFeed(feed), time.Time{}, feeds.NewSeenItemsMock(), feeds.NewOutboxMock(), conv, c.out, c.cfg)
+			_, err := p.Produce(ctx)
+			assert.ErrorIs(t, err, c.err)
+			assert.Equal(t, c.count, len(c.out.Msgs))
+		})
+	}
+}
+
+type rejectingOutput struct {
+	testOutput
+	rejectCount int
+}
+
+func (r *rejectingOutput) WriteBatch(items []*pb.CloudEvent) (ackCount uint32, err error) {
+	if r.rejectCount > 0 {
+		r.rejectCount--
+		return
+	}
+	return r.testOutput.WriteBatch(items)
+}
+
+type failingOutput struct {
+	testOutput
+	down bool
+}
+
+func (f *failingOutput) WriteBatch(items []*pb.CloudEvent) (ackCount uint32, err error) {
+	if f.down {
+		return 0, errors.New("stream is broken")
+	}
+	return f.testOutput.WriteBatch(items)
+}
+
 type testOutput struct {
 	Msgs []*pb.CloudEvent
+	// AckMax is the max number of messages to accept before failing, unlimited if zero
+	AckMax int
 }
 
 func (t *testOutput) Close() error {
@@ -74,6 +274,11 @@ func (t *testOutput) Close() error {
 }
 
 func (t *testOutput) WriteBatch(items []*pb.CloudEvent) (ackCount uint32, err error) {
+	if t.AckMax > 0 && len(t.Msgs)+len(items) > t.AckMax {
+		items = items[:t.AckMax-len(t.Msgs)]
+		t.Msgs = append(t.Msgs, items...)
+		return uint32(len(items)), errors.New("output failure")
+	}
 	t.Msgs = append(t.Msgs, items...)
 	return uint32(len(items)), nil
 }
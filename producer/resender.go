@@ -0,0 +1,50 @@
+package producer
+
+import (
+	"context"
+	"github.com/awakari/client-sdk-go/model"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"producer-rss/config"
+	"producer-rss/feeds"
+	"time"
+)
+
+// Resender sends the outbox entries left unsent by the previous runs, e.g. due to the crash or the output failure.
+// The entries put within the output timeout are skipped, as the concurrent run may still be writing them.
+type Resender interface {
+	Resend(ctx context.Context) (count uint32, err error)
+}
+
+type resender struct {
+	outbox    feeds.Outbox
+	batchSize uint32
+	timeout   time.Duration
+	sender    sender
+}
+
+func NewResender(outbox feeds.Outbox, seen feeds.SeenItems, output model.Writer[*pb.CloudEvent], cfgOutput config.WriterConfig) Resender {
+	return resender{
+		outbox:    outbox,
+		batchSize: cfgOutput.BatchSize,
+		timeout:   cfgOutput.Timeout,
+		sender:    newSender(outbox, seen, output, cfgOutput),
+	}
+}
+
+func (r resender) Resend(ctx context.Context) (count uint32, err error) {
+	var entries []feeds.OutboxEntry
+	var ackCount uint32
+	before := time.Now().Add(-r.timeout)
+	for {
+		entries, err = r.outbox.GetUnsent(ctx, r.batchSize, before)
+		if err != nil || len(entries) == 0 {
+			break
+		}
+		ackCount, err = r.sender.send(ctx, entries)
+		count += ackCount
+		if err != nil {
+			break
+		}
+	}
+	return
+}
@@ -0,0 +1,29 @@
+package producer
+
+import (
+	"context"
+	"fmt"
+	"golang.org/x/exp/slog"
+)
+
+type resenderLogging struct {
+	res Resender
+	log *slog.Logger
+}
+
+func NewResenderLogging(res Resender, log *slog.Logger) Resender {
+	return resenderLogging{
+		res: res,
+		log: log,
+	}
+}
+
+func (rl resenderLogging) Resend(ctx context.Context) (count uint32, err error) {
+	count, err = rl.res.Resend(ctx)
+	if err == nil {
+		rl.log.Debug(fmt.Sprintf("producer.Resend(_): %d", count))
+	} else {
+		rl.log.Warn(fmt.Sprintf("producer.Resend(_): %d, %s", count, err))
+	}
+	return
+}
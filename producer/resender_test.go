@@ -0,0 +1,144 @@
+package producer
+
+import (
+	"context"
+	"github.com/SlyMarbo/rss"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+	"producer-rss/config"
+	"producer-rss/converter"
+	"producer-rss/feeds"
+	"testing"
+	"time"
+)
+
+// cfgResend is the output config with the short timeout, so the entries put by the test are soon old enough to resend.
+var cfgResend = config.WriterConfig{
+	AttemptsMax: 3,
+	Backoff:     time.Millisecond,
+	BackoffMax:  10 * time.Millisecond,
+	BatchSize:   2,
+	Timeout:     100 * time.Millisecond,
+}
+
+func TestResender_Resend(t *testing.T) {
+	feed := &rss.Feed{
+		UpdateURL: "https://test-feed-0.nz",
+	}
+	for _, id := range []string{"item0", "item1", "item2"} {
+		feed.Items = append(feed.Items, &rss.Item{
+			ID: id,
+		})
+	}
+	conv := converter.NewConverter(config.MessageConfig{}, slog.Default())
+	seen := feeds.NewSeenItemsMock()
+	outbox := feeds.NewOutboxMock()
+	// the output fails after the 1st message, the rest remains in the outbox
+	out := &testOutput{
+		AckMax: 1,
+	}
+	p := NewProducer(feeds.NewFeed(feed), time.Time{}, seen, outbox, conv, out, cfgOutput)
+	_, err := p.Produce(context.TODO())
+	require.ErrorIs(t, err, ErrWriteRetriesExhausted)
+	require.Equal(t, 1, len(out.Msgs))
+	//
+	out = &testOutput{}
+	r := NewResender(outbox, seen, out, cfgResend)
+	// the entries put within the output timeout may be still being written by the concurrent run
+	count, err := r.Resend(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(0), count)
+	assert.Equal(t, 0, len(out.Msgs))
+	time.Sleep(cfgResend.Timeout)
+	count, err = r.Resend(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(1), count)
+	assert.Equal(t, 1, len(out.Msgs))
+	// nothing left
+	count, err = r.Resend(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(0), count)
+	// the resent items are seen
+	s, err := seen.GetSeen(context.TODO(), "https://test-feed-0.nz", []string{"item0", "item1", "item2"})
+	require.Nil(t, err)
+	assert.Equal(t, map[string]bool{"item0": true, "item1": true}, s)
+}
+
+func TestResender_Resend_DeadLetter(t *testing.T) {
+	feed := &rss.Feed{
+		UpdateURL: "https://test-feed-0.nz",
+	}
+	for _, id := range []string{"item0", "item1"} {
+		feed.Items = append(feed.Items, &rss.Item{
+			ID: id,
+		})
+	}
+	conv := converter.NewConverter(config.MessageConfig{}, slog.Default())
+	seen := feeds.NewSeenItemsMock()
+	outbox := feeds.NewOutboxMock()
+	// the output rejects every message
+	out := &rejectingOutput{
+		rejectCount: 1000,
+	}
+	p := NewProducer(feeds.NewFeed(feed), time.Time{}, seen, outbox, conv, out, cfgOutput)
+	_, err := p.Produce(context.TODO())
+	require.ErrorIs(t, err, ErrWriteRetriesExhausted)
+	assert.NotErrorIs(t, err, ErrDeadLetter)
+	// the 1st message reaches the max delivery attempts and doesn't block the rest anymore
+	time.Sleep(cfgResend.Timeout)
+	r := NewResender(outbox, seen, out, cfgResend)
+	_, err = r.Resend(context.TODO())
+	assert.ErrorIs(t, err, ErrWriteRetriesExhausted)
+	assert.ErrorIs(t, err, ErrDeadLetter)
+	out.rejectCount = 0
+	count, err := r.Resend(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(1), count)
+	assert.Equal(t, 1, len(out.Msgs))
+	// the dead letter item is seen, so it's not produced again
+	s, err := seen.GetSeen(context.TODO(), "https://test-feed-0.nz", []string{"item0", "item1"})
+	require.Nil(t, err)
+	assert.Equal(t, map[string]bool{"item0": true, "item1": true}, s)
+}
+
+func TestResender_Resend_Outage(t *testing.T) {
+	feed := &rss.Feed{
+		UpdateURL: "https://test-feed-0.nz",
+	}
+	for _, id := range []string{"item0", "item1"} {
+		feed.Items = append(feed.Items, &rss.Item{
+			ID: id,
+		})
+	}
+	conv := converter.NewConverter(config.MessageConfig{}, slog.Default())
+	seen := feeds.NewSeenItemsMock()
+	outbox := feeds.NewOutboxMock()
+	// the output is unavailable, every write fails
+	out := &failingOutput{
+		down: true,
+	}
+	p := NewProducer(feeds.NewFeed(feed), time.Time{}, seen, outbox, conv, out, cfgOutput)
+	_, err := p.Produce(context.TODO())
+	require.ErrorIs(t, err, ErrWriteRetriesExhausted)
+	// the outage doesn't count the delivery attempts, so nothing goes to the dead letters
+	time.Sleep(cfgResend.Timeout)
+	r := NewResender(outbox, seen, out, cfgResend)
+	for i := 0; i < 5; i++ {
+		_, err = r.Resend(context.TODO())
+		assert.ErrorIs(t, err, ErrWriteRetriesExhausted)
+		assert.NotErrorIs(t, err, ErrDeadLetter)
+	}
+	s, err := seen.GetSeen(context.TODO(), "https://test-feed-0.nz", []string{"item0", "item1"})
+	require.Nil(t, err)
+	assert.Empty(t, s)
+	// all the entries are delivered once the output is back
+	out.down = false
+	count, err := r.Resend(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(2), count)
+	assert.Equal(t, 2, len(out.Msgs))
+	s, err = seen.GetSeen(context.TODO(), "https://test-feed-0.nz", []string{"item0", "item1"})
+	require.Nil(t, err)
+	assert.Equal(t, map[string]bool{"item0": true, "item1": true}, s)
+}
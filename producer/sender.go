@@ -0,0 +1,129 @@
+package producer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/awakari/client-sdk-go/model"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"producer-rss/backoff"
+	"producer-rss/config"
+	"producer-rss/feeds"
+	"time"
+)
+
+// ErrWriteRetriesExhausted means the output didn't accept all the messages within the retry attempts and time limits.
+var ErrWriteRetriesExhausted = errors.New("write retries exhausted")
+
+// ErrDeadLetter means the message was not delivered after the max attempts, so it's not sent anymore.
+var ErrDeadLetter = errors.New("message moved to the dead letters")
+
+// sender writes the outbox entries to the output, then marks the accepted entries sent and their items seen.
+// The output write is retried with the exponential backoff while the attempts and the time limits are not exhausted.
+// When the output responds but rejects the message, the first entry not accepted counts the failed delivery attempt, as
+// it blocks the rest. Once it's moved to the dead letters, its item is marked seen to be not produced again.
+// The output errors (transport, stream, timeout) don't count the attempts, the entries remain in the outbox instead.
+type sender struct {
+	outbox        feeds.Outbox
+	seen          feeds.SeenItems
+	output        model.Writer[*pb.CloudEvent]
+	cfgOutput     config.WriterConfig
+	outputBackoff backoff.Exponential
+}
+
+func newSender(outbox feeds.Outbox, seen feeds.SeenItems, output model.Writer[*pb.CloudEvent], cfgOutput config.WriterConfig) sender {
+	return sender{
+		outbox:    outbox,
+		seen:      seen,
+		output:    output,
+		cfgOutput: cfgOutput,
+		outputBackoff: backoff.Exponential{
+			Initial: cfgOutput.Backoff,
+			Max:     cfgOutput.BackoffMax,
+		},
+	}
+}
+
+func (s sender) send(ctx context.Context, entries []feeds.OutboxEntry) (ackCount uint32, err error) {
+	msgCount := uint32(len(entries))
+	msgs := make([]*pb.CloudEvent, msgCount)
+	for i, e := range entries {
+		msgs[i] = e.Msg
+	}
+	// the output responded without the error but didn't accept the next message on the last write
+	var rejected bool
+	defer func() {
+		failed := rejected && err != nil && ackCount < msgCount && ctx.Err() == nil
+		err = errors.Join(err, s.markSent(ctx, entries[:ackCount]))
+		if failed {
+			err = errors.Join(err, s.markFailed(ctx, entries[ackCount]))
+		}
+	}()
+	start := time.Now()
+	// the number of the consecutive failed attempts, reset on any progress
+	var failures uint32
+	var n uint32
+	for ackCount < msgCount {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		n, err = s.output.WriteBatch(msgs[ackCount:])
+		ackCount += n
+		rejected = err == nil
+		switch {
+		case ackCount == msgCount:
+			err = nil
+			continue
+		case err == nil && n > 0:
+			failures = 0
+			continue
+		case n > 0:
+			failures = 0
+		}
+		failures++
+		delay := s.outputBackoff.Delay(failures - 1)
+		if failures >= s.cfgOutput.AttemptsMax || time.Since(start)+delay > s.cfgOutput.Timeout {
+			if err == nil {
+				err = errors.New("no messages accepted")
+			}
+			err = fmt.Errorf("%w: %d attempts in %s, last error: %s", ErrWriteRetriesExhausted, failures, time.Since(start).Round(time.Millisecond), err)
+			return
+		}
+		err = backoff.Sleep(ctx, delay)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (s sender) markSent(ctx context.Context, entries []feeds.OutboxEntry) (err error) {
+	if len(entries) == 0 {
+		return
+	}
+	var msgIds []string
+	seenIdsByUrl := map[string][]string{}
+	for _, e := range entries {
+		msgIds = append(msgIds, e.Msg.Id)
+		if e.ItemId != "" {
+			seenIdsByUrl[e.Url] = append(seenIdsByUrl[e.Url], e.ItemId)
+		}
+	}
+	err = s.outbox.MarkSent(ctx, msgIds)
+	for url, ids := range seenIdsByUrl {
+		err = errors.Join(err, s.seen.SetSeen(ctx, url, ids))
+	}
+	return
+}
+
+func (s sender) markFailed(ctx context.Context, e feeds.OutboxEntry) (err error) {
+	var dead bool
+	dead, err = s.outbox.MarkFailed(ctx, e.Msg.Id)
+	if err == nil && dead {
+		if e.ItemId != "" {
+			err = s.seen.SetSeen(ctx, e.Url, []string{e.ItemId})
+		}
+		err = errors.Join(fmt.Errorf("%w: message %s of the feed %s", ErrDeadLetter, e.Msg.Id, e.Url), err)
+	}
+	return
+}
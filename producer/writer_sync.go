@@ -0,0 +1,32 @@
+package producer
+
+import (
+	"github.com/awakari/client-sdk-go/model"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"sync"
+)
+
+type writerSync struct {
+	w    model.Writer[*pb.CloudEvent]
+	lock *sync.Mutex
+}
+
+// NewWriterSync serializes the batch writes, so the concurrent feed updates may share the same messages writer stream.
+func NewWriterSync(w model.Writer[*pb.CloudEvent]) model.Writer[*pb.CloudEvent] {
+	return writerSync{
+		w:    w,
+		lock: &sync.Mutex{},
+	}
+}
+
+func (ws writerSync) Close() error {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+	return ws.w.Close()
+}
+
+func (ws writerSync) WriteBatch(msgs []*pb.CloudEvent) (ackCount uint32, err error) {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+	return ws.w.WriteBatch(msgs)
+}
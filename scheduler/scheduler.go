@@ -0,0 +1,192 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"producer-rss/producer"
+	"producer-rss/updater"
+	"time"
+)
+
+// Scheduler runs the feed updates periodically until the context is done. The outbox is drained every max update
+// interval too, so the messages failed to send are delivered without waiting for the restart.
+// The run stops when the writer retries are exhausted by an update or a drain, as the writer is down and every next
+// write would fail too. The updates in progress are interrupted then and the write failure is returned.
+type Scheduler interface {
+	Run(ctx context.Context) (err error)
+}
+
+type scheduler struct {
+	upd         updater.Updater
+	res         producer.Resender
+	urls        []string
+	intervalMin time.Duration
+	intervalMax time.Duration
+	concurrency int
+}
+
+type entry struct {
+	url      string
+	next     time.Time
+	failures int
+	// running is true while the feed update is in progress
+	running bool
+}
+
+type result struct {
+	e   *entry
+	r   updater.Report
+	err error
+}
+
+// NewScheduler creates the scheduler running up to the concurrency feed updates at the same time, at least one.
+func NewScheduler(upd updater.Updater, res producer.Resender, urls []string, intervalMin, intervalMax time.Duration, concurrency uint32) Scheduler {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return scheduler{
+		upd:         upd,
+		res:         res,
+		urls:        urls,
+		intervalMin: intervalMin,
+		intervalMax: intervalMax,
+		concurrency: int(concurrency),
+	}
+}
+
+func (s scheduler) Run(ctx context.Context) (err error) {
+	if len(s.urls) == 0 {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	// cancelled to interrupt the updates in progress when the writer is down
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	entries := make([]*entry, len(s.urls))
+	now := time.Now()
+	for i, url := range s.urls {
+		// spread the initial updates evenly over the min interval to avoid the burst on start
+		entries[i] = &entry{
+			url:  url,
+			next: now.Add(time.Duration(i) * s.intervalMin / time.Duration(len(s.urls))),
+		}
+	}
+	results := make(chan result)
+	var running int
+	drainNext := now.Add(s.intervalMax)
+	var draining bool
+	var errWriter error
+	for {
+		// the outbox is drained when no update is in progress, otherwise the entries being sent by the updates may be
+		// sent twice
+		if draining && running == 0 {
+			// the other resend failures are not fatal, the rejected messages are moved to the dead letters eventually
+			_, errRes := s.res.Resend(ctx)
+			if errors.Is(errRes, producer.ErrWriteRetriesExhausted) {
+				errWriter = fmt.Errorf("%w: %w", updater.ErrWrite, errRes)
+			}
+			draining = false
+			drainNext = time.Now().Add(s.intervalMax)
+		}
+		if errWriter != nil {
+			cancel()
+			for ; running > 0; running-- {
+				<-results
+			}
+			return errWriter
+		}
+		// wait for the earliest idle feed only while there is a free slot and no drain is pending
+		var e *entry
+		var t, tDrain *time.Timer
+		var due, drainDue <-chan time.Time
+		if running < s.concurrency && !draining {
+			e = earliest(entries)
+		}
+		if e != nil {
+			t = time.NewTimer(time.Until(e.next))
+			due = t.C
+		}
+		if !draining {
+			tDrain = time.NewTimer(time.Until(drainNext))
+			drainDue = tDrain.C
+		}
+		select {
+		case <-ctx.Done():
+			if t != nil {
+				t.Stop()
+			}
+			if tDrain != nil {
+				tDrain.Stop()
+			}
+			// the updates in progress are interrupted by the same context
+			for ; running > 0; running-- {
+				<-results
+			}
+			return ctx.Err()
+		case res := <-results:
+			running--
+			res.e.running = false
+			if errors.Is(res.err, producer.ErrWriteRetriesExhausted) {
+				errWriter = res.err
+			}
+			if res.err == nil {
+				res.e.failures = 0
+			} else {
+				res.e.failures++
+			}
+			res.e.next = time.Now().Add(s.nextInterval(res.r.UpdateInterval, res.e.failures))
+		case <-due:
+			e.running = true
+			running++
+			go func() {
+				r, errUpd := s.upd.Update(ctx, e.url)
+				results <- result{
+					e:   e,
+					r:   r,
+					err: errUpd,
+				}
+			}()
+		case <-drainDue:
+			draining = true
+		}
+		if t != nil {
+			t.Stop()
+		}
+		if tDrain != nil {
+			tDrain.Stop()
+		}
+	}
+}
+
+// earliest returns the idle entry to be updated first, nil if all entries are running.
+func earliest(entries []*entry) (e *entry) {
+	for _, candidate := range entries {
+		if !candidate.running && (e == nil || candidate.next.Before(e.next)) {
+			e = candidate
+		}
+	}
+	return
+}
+
+// nextInterval returns the learned feed update interval after a successful update, or the max interval if the
+// updater didn't learn it. A failed update is retried with the exponential backoff starting from the min interval.
+func (s scheduler) nextInterval(learned time.Duration, failures int) (next time.Duration) {
+	next = s.intervalMax
+	switch {
+	case failures == 0 && learned > 0:
+		next = learned
+		if next < s.intervalMin {
+			next = s.intervalMin
+		}
+	case failures > 0:
+		next = s.intervalMin
+		for i := 1; i < failures && next < s.intervalMax; i++ {
+			next *= 2
+		}
+	}
+	if next > s.intervalMax {
+		next = s.intervalMax
+	}
+	return
+}
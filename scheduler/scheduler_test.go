@@ -0,0 +1,250 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"producer-rss/producer"
+	"producer-rss/updater"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduler_Run(t *testing.T) {
+	upd := &testUpdater{
+		counts: map[string]int{},
+	}
+	s := NewScheduler(upd, &testResender{upd: upd}, []string{"feed0", "feed1", "fail"}, 15*time.Millisecond, 100*time.Millisecond, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	err := s.Run(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	upd.lock.Lock()
+	defer upd.lock.Unlock()
+	// 0, 100, 200
+	assert.InDelta(t, 3, upd.counts["feed0"], 1)
+	// 5, 55, 105, 155, 205
+	assert.InDelta(t, 5, upd.counts["feed1"], 1)
+	// 10, 25, 55, 115, 215
+	assert.InDelta(t, 5, upd.counts["fail"], 1)
+}
+
+func TestScheduler_Run_Empty(t *testing.T) {
+	s := NewScheduler(&testUpdater{}, &testResender{}, nil, time.Millisecond, time.Second, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, s.Run(ctx), context.DeadlineExceeded)
+}
+
+func TestScheduler_Run_Concurrency(t *testing.T) {
+	cases := map[string]struct {
+		concurrency uint32
+		inFlightMax int
+	}{
+		"sequential": {
+			concurrency: 1,
+			inFlightMax: 1,
+		},
+		"limited": {
+			concurrency: 2,
+			inFlightMax: 2,
+		},
+		"above the feeds count": {
+			concurrency: 10,
+			inFlightMax: 4,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			upd := &testUpdater{
+				counts: map[string]int{},
+				delay:  50 * time.Millisecond,
+			}
+			s := NewScheduler(upd, &testResender{upd: upd}, []string{"feed0", "feed2", "feed3", "feed4"}, time.Millisecond, time.Second, c.concurrency)
+			ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+			defer cancel()
+			err := s.Run(ctx)
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+			upd.lock.Lock()
+			defer upd.lock.Unlock()
+			assert.Equal(t, c.inFlightMax, upd.inFlightMax)
+			// every update is finished before the run returns
+			assert.Equal(t, 0, upd.inFlight)
+		})
+	}
+}
+
+func TestScheduler_Run_Drain(t *testing.T) {
+	upd := &testUpdater{
+		counts: map[string]int{},
+		delay:  10 * time.Millisecond,
+	}
+	res := &testResender{
+		upd: upd,
+	}
+	s := NewScheduler(upd, res, []string{"feed0", "feed1", "fail"}, time.Millisecond, 50*time.Millisecond, 3)
+	ctx, cancel := context.WithTimeout(context.Background(), 275*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, s.Run(ctx), context.DeadlineExceeded)
+	upd.lock.Lock()
+	defer upd.lock.Unlock()
+	// 50, 110, 170, 230 at least, as the drain waits for the updates in progress
+	assert.InDelta(t, 4, res.count, 1)
+	assert.Zero(t, res.inFlightMax)
+	// the updates continue after the drain
+	assert.GreaterOrEqual(t, upd.counts["feed0"], res.count)
+}
+
+func TestScheduler_Run_WriterDown(t *testing.T) {
+	cases := map[string]struct {
+		urls      []string
+		resendErr error
+	}{
+		"update": {
+			urls: []string{"feed0", "writer-down"},
+		},
+		"drain": {
+			urls:      []string{"feed0"},
+			resendErr: producer.ErrWriteRetriesExhausted,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			upd := &testUpdater{
+				counts: map[string]int{},
+				delay:  10 * time.Millisecond,
+			}
+			res := &testResender{
+				upd: upd,
+				err: c.resendErr,
+			}
+			s := NewScheduler(upd, res, c.urls, time.Millisecond, 50*time.Millisecond, 2)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			err := s.Run(ctx)
+			assert.ErrorIs(t, err, updater.ErrWrite)
+			assert.ErrorIs(t, err, producer.ErrWriteRetriesExhausted)
+			// stopped before the timeout, no update is left in progress
+			assert.Nil(t, ctx.Err())
+			upd.lock.Lock()
+			defer upd.lock.Unlock()
+			assert.Equal(t, 0, upd.inFlight)
+		})
+	}
+}
+
+func TestScheduler_nextInterval(t *testing.T) {
+	s := scheduler{
+		intervalMin: 10 * time.Second,
+		intervalMax: 10 * time.Minute,
+	}
+	cases := map[string]struct {
+		learned  time.Duration
+		failures int
+		next     time.Duration
+	}{
+		"not learned": {
+			next: 10 * time.Minute,
+		},
+		"learned": {
+			learned: 3 * time.Minute,
+			next:    3 * time.Minute,
+		},
+		"learned below min": {
+			learned: time.Second,
+			next:    10 * time.Second,
+		},
+		"learned above max": {
+			learned: time.Hour,
+			next:    10 * time.Minute,
+		},
+		"1st failure": {
+			learned:  3 * time.Minute,
+			failures: 1,
+			next:     10 * time.Second,
+		},
+		"2nd failure": {
+			failures: 2,
+			next:     20 * time.Second,
+		},
+		"3rd failure": {
+			failures: 3,
+			next:     40 * time.Second,
+		},
+		"6th failure": {
+			failures: 6,
+			next:     320 * time.Second,
+		},
+		"7th failure": {
+			failures: 7,
+			next:     10 * time.Minute,
+		},
+		"100th failure": {
+			failures: 100,
+			next:     10 * time.Minute,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			assert.Equal(t, c.next, s.nextInterval(c.learned, c.failures))
+		})
+	}
+}
+
+type testUpdater struct {
+	lock        sync.Mutex
+	counts      map[string]int
+	delay       time.Duration
+	inFlight    int
+	inFlightMax int
+}
+
+func (tu *testUpdater) Update(ctx context.Context, url string) (r updater.Report, err error) {
+	tu.lock.Lock()
+	tu.counts[url]++
+	tu.inFlight++
+	if tu.inFlight > tu.inFlightMax {
+		tu.inFlightMax = tu.inFlight
+	}
+	tu.lock.Unlock()
+	if tu.delay > 0 {
+		select {
+		case <-ctx.Done():
+		case <-time.After(tu.delay):
+		}
+	}
+	tu.lock.Lock()
+	defer tu.lock.Unlock()
+	tu.inFlight--
+	r.Url = url
+	switch url {
+	case "feed1":
+		r.UpdateInterval = 50 * time.Millisecond
+	case "fail":
+		err = errors.New("fail")
+	case "writer-down":
+		err = fmt.Errorf("%w: %w", updater.ErrWrite, producer.ErrWriteRetriesExhausted)
+	}
+	return
+}
+
+type testResender struct {
+	upd         *testUpdater
+	count       int
+	inFlightMax int
+	err         error
+}
+
+func (tr *testResender) Resend(ctx context.Context) (count uint32, err error) {
+	tr.upd.lock.Lock()
+	defer tr.upd.lock.Unlock()
+	tr.count++
+	// no update should be in progress while draining
+	if tr.upd.inFlight > tr.inFlightMax {
+		tr.inFlightMax = tr.upd.inFlight
+	}
+	err = tr.err
+	return
+}
@@ -0,0 +1,56 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrFetch means the feed was not fetched: network failure, timeout or unexpected response status.
+var ErrFetch = errors.New("fetch failure")
+
+// ErrParse means the feed was fetched but is not a valid feed document.
+var ErrParse = errors.New("parse failure")
+
+// ErrWrite means some of the new feed items were not written to the output.
+var ErrWrite = errors.New("write failure")
+
+// ErrStorage means the feed state was not read from or saved to the storage.
+var ErrStorage = errors.New("storage failure")
+
+// The process exit codes by the update error class. The codes 1 and 2 are reserved for the unclassified errors and the
+// Go runtime panics respectively.
+const (
+	ExitCodeOk      = 0
+	ExitCodeUnknown = 1
+	ExitCodeFetch   = 3
+	ExitCodeParse   = 4
+	ExitCodeWrite   = 5
+	ExitCodeStorage = 6
+)
+
+// ExitCode returns the process exit code for the run error, possibly joined from multiple feed update errors.
+// When the error contains multiple classes, the most severe one wins: storage, write, parse, fetch.
+func ExitCode(err error) (code int) {
+	switch {
+	case err == nil:
+		code = ExitCodeOk
+	case errors.Is(err, ErrStorage):
+		code = ExitCodeStorage
+	case errors.Is(err, ErrWrite):
+		code = ExitCodeWrite
+	case errors.Is(err, ErrParse):
+		code = ExitCodeParse
+	case errors.Is(err, ErrFetch):
+		code = ExitCodeFetch
+	default:
+		code = ExitCodeUnknown
+	}
+	return
+}
+
+func wrapErr(class, err error) (wrapped error) {
+	if err != nil {
+		wrapped = fmt.Errorf("%w: %w", class, err)
+	}
+	return
+}
@@ -0,0 +1,51 @@
+package updater
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	cases := map[string]struct {
+		err  error
+		code int
+	}{
+		"ok": {
+			code: ExitCodeOk,
+		},
+		"unknown": {
+			err:  errors.New("unknown"),
+			code: ExitCodeUnknown,
+		},
+		"fetch": {
+			err:  wrapErr(ErrFetch, errors.New("timeout")),
+			code: ExitCodeFetch,
+		},
+		"parse": {
+			err:  wrapErr(ErrParse, errors.New("EOF")),
+			code: ExitCodeParse,
+		},
+		"write": {
+			err:  wrapErr(ErrWrite, errors.New("unavailable")),
+			code: ExitCodeWrite,
+		},
+		"storage": {
+			err:  wrapErr(ErrStorage, errors.New("timeout")),
+			code: ExitCodeStorage,
+		},
+		"most severe wins": {
+			err: errors.Join(
+				wrapErr(ErrFetch, errors.New("timeout")),
+				wrapErr(ErrWrite, errors.New("unavailable")),
+				wrapErr(ErrParse, errors.New("EOF")),
+			),
+			code: ExitCodeWrite,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			assert.Equal(t, c.code, ExitCode(c.err))
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package updater
+
+import (
+	"github.com/SlyMarbo/rss"
+	"time"
+)
+
+// learnInterval estimates the feed update interval as the average time between the distinct item publications,
+// counting the time passed since the oldest item till now. This way both a frequent feed (e.g. news) and a feed
+// publishing the items once a day in a single batch (e.g. arxiv) get the adequate interval.
+// The estimation is smoothed with the previously learned interval, if any, and is limited by the min and max.
+// When the feed contains no dated items, the previously learned interval is kept.
+func learnInterval(prev time.Duration, items []*rss.Item, now time.Time, min, max time.Duration) (d time.Duration) {
+	var oldest time.Time
+	dates := map[time.Time]bool{}
+	for _, item := range items {
+		if item.Date.IsZero() || item.Date.After(now) {
+			continue
+		}
+		dates[item.Date] = true
+		if oldest.IsZero() || item.Date.Before(oldest) {
+			oldest = item.Date
+		}
+	}
+	switch {
+	case len(dates) == 0 && prev > 0:
+		d = prev
+	case len(dates) == 0:
+		d = max
+	default:
+		d = now.Sub(oldest) / time.Duration(len(dates))
+		if prev > 0 {
+			d = (d + prev) / 2
+		}
+	}
+	if d < min {
+		d = min
+	}
+	if d > max {
+		d = max
+	}
+	return
+}
@@ -0,0 +1,75 @@
+package updater
+
+import (
+	"github.com/SlyMarbo/rss"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLearnInterval(t *testing.T) {
+	now := time.Date(2023, 6, 9, 12, 0, 0, 0, time.UTC)
+	cases := map[string]struct {
+		prev  time.Duration
+		items []*rss.Item
+		d     time.Duration
+	}{
+		"no items": {
+			d: time.Hour,
+		},
+		"no dated items keep previous": {
+			prev: 20 * time.Minute,
+			items: []*rss.Item{
+				{},
+			},
+			d: 20 * time.Minute,
+		},
+		"frequent": {
+			items: []*rss.Item{
+				{Date: now.Add(-10 * time.Minute)},
+				{Date: now.Add(-20 * time.Minute)},
+				{Date: now.Add(-30 * time.Minute)},
+			},
+			d: 10 * time.Minute,
+		},
+		"too frequent": {
+			items: []*rss.Item{
+				{Date: now.Add(-1 * time.Second)},
+				{Date: now.Add(-2 * time.Second)},
+				{Date: now.Add(-3 * time.Second)},
+			},
+			d: time.Minute,
+		},
+		"daily batch": {
+			items: []*rss.Item{
+				{Date: now.Add(-10 * time.Hour)},
+				{Date: now.Add(-10 * time.Hour)},
+				{Date: now.Add(-10 * time.Hour)},
+			},
+			d: time.Hour,
+		},
+		"smoothed": {
+			prev: 30 * time.Minute,
+			items: []*rss.Item{
+				{Date: now.Add(-10 * time.Minute)},
+				{Date: now.Add(-20 * time.Minute)},
+				{Date: now.Add(-30 * time.Minute)},
+			},
+			d: 20 * time.Minute,
+		},
+		"future dates ignored": {
+			items: []*rss.Item{
+				{Date: now.Add(time.Hour)},
+				{Date: now.Add(-10 * time.Minute)},
+				{Date: now.Add(-20 * time.Minute)},
+			},
+			d: 10 * time.Minute,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			d := learnInterval(c.prev, c.items, now, time.Minute, time.Hour)
+			assert.Equal(t, c.d, d)
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/awakari/client-sdk-go/model"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"golang.org/x/exp/slog"
+	"producer-rss/config"
+	"producer-rss/converter"
+	"producer-rss/feeds"
+	"producer-rss/producer"
+	"time"
+)
+
+// Updater fetches the feed by the specified URL and produces the messages for the new feed items.
+type Updater interface {
+	// Update fetches the feed and produces the messages for the feed items newer than the update time checkpoint.
+	// The returned error is classified as one of ErrFetch, ErrParse, ErrWrite or ErrStorage.
+	// The checkpoint (update time and response validators) policy by the outcome:
+	//   - success: both are advanced;
+	//   - not modified: both are kept;
+	//   - fetch or parse failure: both are kept, so the feed is fetched in full next time;
+	//   - write failure: the update time is advanced up to the newest item sent before the first unsent one, the
+	//     validators are kept, so the unsent items are produced again next time;
+	//   - seen items or outbox failure while producing: same as the write failure;
+	//   - other storage failure: whatever is not saved is kept.
+	Update(ctx context.Context, url string) (r Report, err error)
+}
+
+// Report is the outcome of a single feed update.
+type Report struct {
+	Url        string
+	ItemCount  int
+	UpdateTime time.Time
+	// UpdateInterval is the learned interval to the next feed update.
+	UpdateInterval time.Duration
+	// NotModified is true when the feed didn't change since the last update, so nothing was produced.
+	NotModified bool
+	// MovedTo is the new feed URL when the feed has moved permanently, either during this update or before.
+	MovedTo string
+	// Status is the feed health status after the update.
+	Status feeds.Status
+	// Skipped is true when the feed is retired, so it was not updated.
+	Skipped bool
+}
+
+type updater struct {
+	client    feeds.Client
+	stor      feeds.Storage
+	seen      feeds.SeenItems
+	outbox    feeds.Outbox
+	conv      converter.Converter
+	output    model.Writer[*pb.CloudEvent]
+	cfgOutput config.WriterConfig
+	cfgFeed   config.FeedConfig
+	log       *slog.Logger
+}
+
+func NewUpdater(
+	client feeds.Client,
+	stor feeds.Storage,
+	seen feeds.SeenItems,
+	outbox feeds.Outbox,
+	conv converter.Converter,
+	output model.Writer[*pb.CloudEvent],
+	cfgOutput config.WriterConfig,
+	cfgFeed config.FeedConfig,
+	log *slog.Logger,
+) Updater {
+	return updater{
+		client:    client,
+		stor:      stor,
+		seen:      seen,
+		outbox:    outbox,
+		conv:      conv,
+		output:    output,
+		cfgOutput: cfgOutput,
+		cfgFeed:   cfgFeed,
+		log:       log,
+	}
+}
+
+func (u updater) Update(ctx context.Context, url string) (r Report, err error) {
+	r.Url = url
+	//
+	var rec feeds.Record
+	rec, err = u.stor.Get(ctx, url)
+	if err != nil {
+		err = wrapErr(ErrStorage, err)
+		return
+	}
+	if rec.Url != url {
+		r.MovedTo = rec.Url
+		url = rec.Url
+	}
+	updTime := rec.UpdateTime
+	r.UpdateTime = updTime
+	r.UpdateInterval = rec.UpdateInterval
+	r.Status = rec.Status
+	if r.Status.Retired() && u.cfgFeed.RetiredSkip {
+		r.Skipped = true
+		return
+	}
+	//
+	var fr feeds.FetchResult
+	fr, err = feeds.Fetch(ctx, u.client, url, rec.Validators)
+	var errStatus error
+	if ctx.Err() == nil {
+		errStatus = wrapErr(ErrStorage, u.trackStatus(ctx, url, err, &r))
+	}
+	switch {
+	case errors.Is(err, feeds.ErrNotModified):
+		// nothing to produce, keep the update time as is
+		r.NotModified = true
+		err = errors.Join(errStatus, wrapErr(ErrStorage, u.trackRedirect(ctx, url, rec.Redirect, fr.Location, &r)))
+		return
+	case errors.Is(err, feeds.ErrParse):
+		err = errors.Join(wrapErr(ErrParse, err), errStatus)
+		return
+	case err != nil:
+		err = errors.Join(wrapErr(ErrFetch, err), errStatus)
+		return
+	case errStatus != nil:
+		err = errStatus
+		return
+	}
+	feed := fr.Feed
+	// the feed options are listed by the original URL
+	feed.ListUrl = r.Url
+	r.ItemCount = len(feed.Items)
+	r.UpdateInterval = learnInterval(r.UpdateInterval, feed.Items, time.Now().UTC(), u.cfgFeed.UpdateIntervalMin, u.cfgFeed.UpdateIntervalMax)
+	err = wrapErr(ErrStorage, u.stor.SetUpdateInterval(ctx, url, r.UpdateInterval))
+	if err != nil {
+		return
+	}
+	//
+	prod := producer.NewProducer(feed, updTime, u.seen, u.outbox, u.conv, u.output, u.cfgOutput)
+	prod = producer.NewProducerLogging(prod, u.log)
+	var newUpdTime time.Time
+	newUpdTime, err = prod.Produce(ctx)
+	if err != nil {
+		if errors.Is(err, feeds.ErrInternal) {
+			// the seen items store or the outbox failed
+			err = wrapErr(ErrStorage, err)
+		} else {
+			err = wrapErr(ErrWrite, err)
+		}
+		// advance the checkpoint up to the last sent item only, the unsent items are produced again next time
+		if newUpdTime.After(updTime) {
+			errSet := u.stor.SetUpdateTime(ctx, url, newUpdTime)
+			if errSet == nil {
+				r.UpdateTime = newUpdTime
+			}
+			err = errors.Join(err, wrapErr(ErrStorage, errSet))
+		}
+		return
+	}
+	if !newUpdTime.After(updTime) {
+		newUpdTime = time.Now().UTC()
+	}
+	err = u.stor.SetUpdateTime(ctx, url, newUpdTime)
+	if err == nil {
+		r.UpdateTime = newUpdTime
+		err = u.stor.SetValidators(ctx, url, fr.Validators)
+	}
+	if err == nil {
+		err = u.trackRedirect(ctx, url, rec.Redirect, fr.Location, &r)
+	}
+	err = wrapErr(ErrStorage, err)
+	return
+}
+
+// trackStatus updates the feed health status depending on the fetch result.
+// The feed is retired immediately when it's gone. The feed is disabled when it's missing or not parseable for the
+// max failures count consecutive updates, any other failure resets this count.
+func (u updater) trackStatus(ctx context.Context, url string, errFetch error, r *Report) (err error) {
+	s := r.Status
+	switch {
+	case errFetch == nil, errors.Is(errFetch, feeds.ErrNotModified):
+		s = feeds.Status{
+			State: feeds.StateActive,
+		}
+	default:
+		s.Failures++
+		s.LastError = errFetch.Error()
+		if errors.Is(errFetch, feeds.ErrNotFound) || errors.Is(errFetch, feeds.ErrParse) {
+			s.DeadFailures++
+		} else {
+			s.DeadFailures = 0
+		}
+		switch {
+		case errors.Is(errFetch, feeds.ErrGone):
+			s.State = feeds.StateGone
+		case s.DeadFailures >= u.cfgFeed.FailuresMax:
+			s.State = feeds.StateDisabled
+		default:
+			s.State = feeds.StateFailing
+		}
+	}
+	if s != r.Status {
+		err = u.stor.SetStatus(ctx, url, s)
+		if err == nil {
+			if s.Retired() {
+				u.log.Warn(fmt.Sprintf("feed %s is retired: %s, %d consecutive failures, last error: %s", url, s.State, s.Failures, s.LastError))
+			}
+			r.Status = s
+		}
+	}
+	return
+}
+
+// trackRedirect counts the consecutive permanent redirects to the same location.
+// Once the count reaches the threshold, the feed record is migrated to the new location together with the seen items.
+func (u updater) trackRedirect(ctx context.Context, url string, rd feeds.Redirect, location string, r *Report) (err error) {
+	if location == "" || location == url {
+		if rd.Count > 0 {
+			err = u.stor.SetRedirect(ctx, url, feeds.Redirect{})
+		}
+		return
+	}
+	if location == rd.Url {
+		rd.Count++
+	} else {
+		rd = feeds.Redirect{
+			Url:   location,
+			Count: 1,
+		}
+	}
+	if rd.Count < u.cfgFeed.RedirectCountMin {
+		err = u.stor.SetRedirect(ctx, url, rd)
+	} else {
+		err = u.stor.Migrate(ctx, url, location)
+		if err == nil {
+			// the items produced before are not produced again from the new URL
+			err = u.seen.Migrate(ctx, url, location)
+		}
+		if err == nil {
+			r.MovedTo = location
+			u.log.Warn(fmt.Sprintf("feed %s has moved permanently to %s, update the feed URLs list", r.Url, location))
+		}
+	}
+	return
+}
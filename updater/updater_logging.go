@@ -0,0 +1,33 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"golang.org/x/exp/slog"
+	"time"
+)
+
+type updaterLogging struct {
+	upd Updater
+	log *slog.Logger
+}
+
+func NewUpdaterLogging(upd Updater, log *slog.Logger) Updater {
+	return updaterLogging{
+		upd: upd,
+		log: log,
+	}
+}
+
+func (ul updaterLogging) Update(ctx context.Context, url string) (r Report, err error) {
+	r, err = ul.upd.Update(ctx, url)
+	switch {
+	case err == nil && r.NotModified:
+		ul.log.Debug(fmt.Sprintf("updater.Update(url=%s): not modified, next in %s", url, r.UpdateInterval))
+	case err == nil:
+		ul.log.Debug(fmt.Sprintf("updater.Update(url=%s): %d items, %s, next in %s", url, r.ItemCount, r.UpdateTime.Format(time.RFC3339), r.UpdateInterval))
+	default:
+		ul.log.Error(fmt.Sprintf("updater.Update(url=%s): %d items, %s", url, r.ItemCount, err))
+	}
+	return
+}
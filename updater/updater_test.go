@@ -0,0 +1,405 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"github.com/awakari/client-sdk-go/model"
+	"github.com/cloudevents/sdk-go/binding/format/protobuf/v2/pb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"producer-rss/config"
+	"producer-rss/converter"
+	"producer-rss/feeds"
+	"strings"
+	"testing"
+	"time"
+)
+
+var cfgFeed = config.FeedConfig{
+	FailuresMax:       2,
+	RedirectCountMin:  2,
+	RetiredSkip:       true,
+	UpdateIntervalMin: time.Minute,
+	UpdateIntervalMax: time.Hour,
+}
+
+var cfgOutput = config.WriterConfig{
+	AttemptsMax: 2,
+	Backoff:     time.Millisecond,
+	BackoffMax:  time.Millisecond,
+	BatchSize:   2,
+	Timeout:     time.Second,
+}
+
+func TestUpdater_Update(t *testing.T) {
+	updTimes := map[string]time.Time{
+		"https://feed0.com/rss": time.Date(2004, 10, 19, 15, 9, 0, 0, time.UTC),
+	}
+	stor := feeds.NewStorageMock(updTimes)
+	var cfgMsg config.MessageConfig
+	cfgMsg.Metadata.KeyTitle = "title"
+	conv := converter.NewConverter(cfgMsg, slog.Default())
+	cases := map[string]struct {
+		url        string
+		itemCount  int
+		msgCount   int
+		updateTime time.Time
+		err        error
+	}{
+		"new feed": {
+			url:        "https://feed1.com/rss",
+			itemCount:  9,
+			msgCount:   9,
+			updateTime: time.Date(2004, 10, 19, 15, 9, 11, 0, time.UTC),
+		},
+		"known feed": {
+			url:        "https://feed0.com/rss",
+			itemCount:  9,
+			msgCount:   6,
+			updateTime: time.Date(2004, 10, 19, 15, 9, 11, 0, time.UTC),
+		},
+		"storage failure": {
+			url: "storage-fail",
+			err: ErrStorage,
+		},
+		"fetch failure": {
+			url: "fetch-fail",
+			err: ErrFetch,
+		},
+		"parse failure": {
+			url: "parse-fail",
+			err: ErrParse,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			out := &testOutput{}
+			upd := NewUpdater(feeds.NewClientMock(), stor, feeds.NewSeenItemsMock(), feeds.NewOutboxMock(), conv, out, cfgOutput, cfgFeed, slog.Default())
+			upd = NewUpdaterLogging(upd, slog.Default())
+			r, err := upd.Update(context.TODO(), c.url)
+			assert.Equal(t, c.url, r.Url)
+			assert.Equal(t, c.itemCount, r.ItemCount)
+			assert.Equal(t, c.msgCount, len(out.Msgs))
+			if c.err != nil {
+				assert.ErrorIs(t, err, c.err)
+			} else {
+				assert.Nil(t, err)
+				assert.True(t, c.updateTime.Equal(r.UpdateTime))
+				assert.True(t, c.updateTime.Equal(updTimes[c.url]))
+				// the mock feed items are too old
+				assert.Equal(t, time.Hour, r.UpdateInterval)
+			}
+		})
+	}
+}
+
+func TestUpdater_Update_NotModified(t *testing.T) {
+	updTimes := map[string]time.Time{}
+	stor := feeds.NewStorageMock(updTimes)
+	var cfgMsg config.MessageConfig
+	conv := converter.NewConverter(cfgMsg, slog.Default())
+	out := &testOutput{}
+	upd := NewUpdater(feeds.NewClientMock(), stor, feeds.NewSeenItemsMock(), feeds.NewOutboxMock(), conv, out, cfgOutput, cfgFeed, slog.Default())
+	//
+	r, err := upd.Update(context.TODO(), "https://feed0.com/rss")
+	assert.Nil(t, err)
+	assert.False(t, r.NotModified)
+	assert.Equal(t, 9, len(out.Msgs))
+	updTime := updTimes["https://feed0.com/rss"]
+	//
+	r, err = upd.Update(context.TODO(), "https://feed0.com/rss")
+	assert.Nil(t, err)
+	assert.True(t, r.NotModified)
+	assert.Equal(t, 9, len(out.Msgs))
+	assert.Equal(t, updTime, r.UpdateTime)
+	assert.Equal(t, updTime, updTimes["https://feed0.com/rss"])
+}
+
+func TestUpdater_Update_Moved(t *testing.T) {
+	updTimes := map[string]time.Time{}
+	stor := feeds.NewStorageMock(updTimes)
+	var cfgMsg config.MessageConfig
+	conv := converter.NewConverter(cfgMsg, slog.Default())
+	out := &testOutput{}
+	upd := NewUpdater(feeds.NewClientMock(), stor, feeds.NewSeenItemsMock(), feeds.NewOutboxMock(), conv, out, cfgOutput, cfgFeed, slog.Default())
+	//
+	r, err := upd.Update(context.TODO(), "https://moved.com/rss")
+	assert.Nil(t, err)
+	assert.Equal(t, "", r.MovedTo)
+	assert.Equal(t, 9, len(out.Msgs))
+	//
+	r, err = upd.Update(context.TODO(), "https://moved.com/rss")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://new.com/rss", r.MovedTo)
+	assert.Equal(t, r.UpdateTime, updTimes["https://new.com/rss"])
+	_, found := updTimes["https://moved.com/rss"]
+	assert.False(t, found)
+	// the old URL is resolved to the new one
+	r, err = upd.Update(context.TODO(), "https://moved.com/rss")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://moved.com/rss", r.Url)
+	assert.Equal(t, "https://new.com/rss", r.MovedTo)
+	assert.Equal(t, 9, r.ItemCount)
+	// the items produced before the migration are seen, nothing is produced again
+	assert.Equal(t, 9, len(out.Msgs))
+}
+
+func TestUpdater_Update_Moved_Seen(t *testing.T) {
+	updTimes := map[string]time.Time{}
+	stor := feeds.NewStorageMock(updTimes)
+	var cfgMsg config.MessageConfig
+	conv := converter.NewConverter(cfgMsg, slog.Default())
+	out := &testOutput{}
+	upd := NewUpdater(movedClient{}, stor, feeds.NewSeenItemsMock(), feeds.NewOutboxMock(), conv, out, cfgOutput, cfgFeed, slog.Default())
+	// the undated items are filtered by the seen items only
+	for i := 0; i < 3; i++ {
+		r, err := upd.Update(context.TODO(), "https://moved.com/rss")
+		assert.Nil(t, err)
+		assert.Equal(t, 2, r.ItemCount)
+	}
+	rec, err := stor.Get(context.TODO(), "https://moved.com/rss")
+	require.Nil(t, err)
+	assert.Equal(t, "https://new.com/rss", rec.Url)
+	// the feed URLs list is updated to the new URL
+	r, err := upd.Update(context.TODO(), "https://new.com/rss")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, r.ItemCount)
+	// the items produced before the migration are not produced again
+	assert.Equal(t, 2, len(out.Msgs))
+}
+
+func TestUpdater_Update_Status(t *testing.T) {
+	stor := feeds.NewStorageMock(map[string]time.Time{})
+	var cfgMsg config.MessageConfig
+	conv := converter.NewConverter(cfgMsg, slog.Default())
+	out := &testOutput{}
+	upd := NewUpdater(feeds.NewClientMock(), stor, feeds.NewSeenItemsMock(), feeds.NewOutboxMock(), conv, out, cfgOutput, cfgFeed, slog.Default())
+	cases := map[string]struct {
+		url      string
+		statuses []feeds.Status
+	}{
+		"active": {
+			url: "https://feed0.com/rss",
+			statuses: []feeds.Status{
+				{State: feeds.StateActive},
+				{State: feeds.StateActive},
+			},
+		},
+		"gone": {
+			url: "gone",
+			statuses: []feeds.Status{
+				{State: feeds.StateGone, Failures: 1, LastError: "gone"},
+				{State: feeds.StateGone, Failures: 1, LastError: "gone"},
+			},
+		},
+		"not found": {
+			url: "missing",
+			statuses: []feeds.Status{
+				{State: feeds.StateFailing, Failures: 1, DeadFailures: 1, LastError: "not found"},
+				{State: feeds.StateDisabled, Failures: 2, DeadFailures: 2, LastError: "not found"},
+				{State: feeds.StateDisabled, Failures: 2, DeadFailures: 2, LastError: "not found"},
+			},
+		},
+		"unavailable is never disabled": {
+			url: "unavailable",
+			statuses: []feeds.Status{
+				{State: feeds.StateFailing, Failures: 1, LastError: "unexpected response status: 503"},
+				{State: feeds.StateFailing, Failures: 2, LastError: "unexpected response status: 503"},
+				{State: feeds.StateFailing, Failures: 3, LastError: "unexpected response status: 503"},
+			},
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			for i, s := range c.statuses {
+				r, err := upd.Update(context.TODO(), c.url)
+				if s.State == feeds.StateActive {
+					assert.Nil(t, err)
+				}
+				assert.Equal(t, s, r.Status)
+				assert.Equal(t, i > 0 && c.statuses[i-1].Retired(), r.Skipped)
+			}
+		})
+	}
+	// re-enabled
+	require.Nil(t, stor.SetStatus(context.TODO(), "gone", feeds.Status{}))
+	r, err := upd.Update(context.TODO(), "gone")
+	assert.NotNil(t, err)
+	assert.False(t, r.Skipped)
+}
+
+func TestUpdater_trackStatus(t *testing.T) {
+	u := updater{
+		stor: feeds.NewStorageMock(map[string]time.Time{}),
+		log:  slog.Default(),
+		cfgFeed: config.FeedConfig{
+			FailuresMax: 2,
+		},
+	}
+	errTimeout := context.DeadlineExceeded
+	cases := map[string]struct {
+		errs  []error
+		state feeds.State
+	}{
+		"timeouts then not found": {
+			errs:  []error{errTimeout, errTimeout, errTimeout, errTimeout, feeds.ErrNotFound},
+			state: feeds.StateFailing,
+		},
+		"consecutive not found": {
+			errs:  []error{errTimeout, feeds.ErrNotFound, feeds.ErrNotFound},
+			state: feeds.StateDisabled,
+		},
+		"not found and parse failure": {
+			errs:  []error{feeds.ErrParse, feeds.ErrNotFound},
+			state: feeds.StateDisabled,
+		},
+		"interrupted by timeout": {
+			errs:  []error{feeds.ErrNotFound, errTimeout, feeds.ErrNotFound},
+			state: feeds.StateFailing,
+		},
+		"interrupted by success": {
+			errs:  []error{feeds.ErrNotFound, nil, feeds.ErrNotFound},
+			state: feeds.StateFailing,
+		},
+	}
+	for k, c := range cases {
+		t.Run(k, func(t *testing.T) {
+			var r Report
+			for _, errFetch := range c.errs {
+				require.Nil(t, u.trackStatus(context.TODO(), "https://feed0.com/rss", errFetch, &r))
+			}
+			assert.Equal(t, c.state, r.Status.State)
+		})
+	}
+}
+
+func TestUpdater_Update_WriteFailure(t *testing.T) {
+	updTime := time.Date(2004, 10, 19, 15, 9, 0, 0, time.UTC)
+	updTimes := map[string]time.Time{
+		"https://feed0.com/rss": updTime,
+	}
+	stor := feeds.NewStorageMock(updTimes)
+	var cfgMsg config.MessageConfig
+	conv := converter.NewConverter(cfgMsg, slog.Default())
+	out := &testOutput{
+		Err: errors.New("writer failure"),
+	}
+	upd := NewUpdater(feeds.NewClientMock(), stor, feeds.NewSeenItemsMock(), feeds.NewOutboxMock(), conv, out, cfgOutput, cfgFeed, slog.Default())
+	//
+	r, err := upd.Update(context.TODO(), "https://feed0.com/rss")
+	assert.ErrorIs(t, err, ErrWrite)
+	assert.Equal(t, 9, r.ItemCount)
+	// the checkpoint is kept
+	assert.Equal(t, updTime, r.UpdateTime)
+	assert.Equal(t, updTime, updTimes["https://feed0.com/rss"])
+	rec, err := stor.Get(context.TODO(), "https://feed0.com/rss")
+	require.Nil(t, err)
+	assert.Equal(t, feeds.Validators{}, rec.Validators)
+	// the unsent items are produced next time
+	out.Err = nil
+	r, err = upd.Update(context.TODO(), "https://feed0.com/rss")
+	assert.Nil(t, err)
+	assert.Equal(t, 6, len(out.Msgs))
+}
+
+func TestUpdater_Update_OutboxFailure(t *testing.T) {
+	updTimes := map[string]time.Time{
+		"https://feed0.com/rss": time.Date(2004, 10, 19, 15, 9, 0, 0, time.UTC),
+	}
+	stor := feeds.NewStorageMock(updTimes)
+	var cfgMsg config.MessageConfig
+	conv := converter.NewConverter(cfgMsg, slog.Default())
+	out := &testOutput{}
+	// the 2nd batch is not persisted
+	outbox := &testOutbox{
+		Outbox: feeds.NewOutboxMock(),
+		putMax: 1,
+	}
+	upd := NewUpdater(feeds.NewClientMock(), stor, feeds.NewSeenItemsMock(), outbox, conv, out, cfgOutput, cfgFeed, slog.Default())
+	//
+	r, err := upd.Update(context.TODO(), "https://feed0.com/rss")
+	assert.ErrorIs(t, err, ErrStorage)
+	assert.Equal(t, 2, len(out.Msgs))
+	// the checkpoint is advanced up to the last sent item
+	updTime := time.Date(2004, 10, 19, 15, 9, 3, 0, time.UTC)
+	assert.True(t, updTime.Equal(r.UpdateTime))
+	assert.True(t, updTime.Equal(updTimes["https://feed0.com/rss"]))
+	// the unsent items are produced next time
+	outbox.putMax = 10
+	r, err = upd.Update(context.TODO(), "https://feed0.com/rss")
+	assert.Nil(t, err)
+	assert.Equal(t, 6, len(out.Msgs))
+}
+
+type testOutbox struct {
+	feeds.Outbox
+	putMax int
+	puts   int
+}
+
+func (to *testOutbox) Put(ctx context.Context, entries []feeds.OutboxEntry) (err error) {
+	if to.puts >= to.putMax {
+		return feeds.ErrInternal
+	}
+	to.puts++
+	return to.Outbox.Put(ctx, entries)
+}
+
+type testOutput struct {
+	Msgs []*pb.CloudEvent
+	Err  error
+}
+
+func (t *testOutput) Close() error {
+	return nil
+}
+
+func (t *testOutput) WriteBatch(items []*pb.CloudEvent) (ackCount uint32, err error) {
+	if t.Err != nil {
+		return 0, t.Err
+	}
+	t.Msgs = append(t.Msgs, items...)
+	return uint32(len(items)), nil
+}
+
+var _ model.Writer[*pb.CloudEvent] = (*testOutput)(nil)
+
+// movedClient serves the feed of the undated items, the old URL is redirected permanently to the new one.
+type movedClient struct{}
+
+const rssUndated = `<?xml version="1.0"?>
+<rss version="2.0">
+  <channel>
+    <title>Undated</title>
+    <item>
+      <guid>item0</guid>
+      <title>Item 0</title>
+    </item>
+    <item>
+      <guid>item1</guid>
+      <title>Item 1</title>
+    </item>
+  </channel>
+</rss>`
+
+func (mc movedClient) Get(ctx context.Context, url string, v feeds.Validators) (resp *http.Response, err error) {
+	resp = &http.Response{}
+	resp.StatusCode = http.StatusOK
+	resp.Body = io.NopCloser(strings.NewReader(rssUndated))
+	if url == "https://moved.com/rss" {
+		resp.Request = &http.Request{
+			URL: &neturl.URL{Scheme: "https", Host: "new.com", Path: "/rss"},
+			Response: &http.Response{
+				StatusCode: http.StatusMovedPermanently,
+				Request: &http.Request{
+					URL: &neturl.URL{Scheme: "https", Host: "moved.com", Path: "/rss"},
+				},
+			},
+		}
+	}
+	return
+}